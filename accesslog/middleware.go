@@ -0,0 +1,154 @@
+// Package accesslog writes one structured log line per HTTP request. The
+// lines are written with the context of the request, so behind otelhttp and
+// log.TraceHandler each line carries the trace_id and span_id of the server
+// span.
+package accesslog
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// DefaultMessage is the message of the access log lines
+const DefaultMessage = "HTTP request"
+
+// DurationKey is the request duration in milliseconds
+const DurationKey = "duration_ms"
+
+// Config holds configuration for Middleware
+type Config struct {
+	// Logger writes the lines. Defaults to slog.Default() at the time of
+	// the request, so the logger set up after the routes is used.
+	Logger *slog.Logger
+
+	// Level of the lines of successful requests. Defaults to info.
+	Level slog.Level
+
+	// ServerErrorLevel is the level of the lines of 5xx responses. Defaults
+	// to Level.
+	ServerErrorLevel *slog.Level
+
+	// Message of the lines. Defaults to DefaultMessage.
+	Message string
+
+	// Group nests the fields under a group, e.g. http. Optional.
+	Group string
+
+	// TrustProxy takes the client IP from X-Forwarded-For or X-Real-IP
+	// instead of the remote address. Only enable it behind a proxy that
+	// sets these headers.
+	TrustProxy bool
+}
+
+// Middleware logs the method, route (the registered pattern, not the
+// request path), status code, duration, response size and client IP of
+// every request handled by next
+func Middleware(route string, next http.Handler, config *Config) http.Handler {
+	cfg := Config{Message: DefaultMessage}
+	if config != nil {
+		cfg.Logger = config.Logger
+		cfg.Level = config.Level
+		cfg.ServerErrorLevel = config.ServerErrorLevel
+		if config.Message != "" {
+			cfg.Message = config.Message
+		}
+		cfg.Group = config.Group
+		cfg.TrustProxy = config.TrustProxy
+	}
+	serverErrorLevel := cfg.Level
+	if cfg.ServerErrorLevel != nil {
+		serverErrorLevel = *cfg.ServerErrorLevel
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			logger := cfg.Logger
+			if logger == nil {
+				logger = slog.Default()
+			}
+			level := cfg.Level
+			if rw.status >= http.StatusInternalServerError {
+				level = serverErrorLevel
+			}
+			ctx := r.Context()
+			if !logger.Enabled(ctx, level) {
+				return
+			}
+
+			attrs := []slog.Attr{
+				slog.String(string(semconv.HTTPRequestMethodKey), r.Method),
+				slog.String(string(semconv.HTTPRouteKey), route),
+				slog.String(string(semconv.URLPathKey), r.URL.Path),
+				slog.Int(string(semconv.HTTPResponseStatusCodeKey), rw.status),
+				slog.Float64(DurationKey, float64(time.Since(start).Microseconds())/1000),
+				slog.Int64(string(semconv.HTTPResponseBodySizeKey), rw.written),
+				slog.String(string(semconv.ClientAddressKey), ClientIP(r, cfg.TrustProxy)),
+			}
+			if ua := r.UserAgent(); ua != "" {
+				attrs = append(attrs, slog.String(string(semconv.UserAgentOriginalKey), ua))
+			}
+			if cfg.Group != "" {
+				attrs = []slog.Attr{{Key: cfg.Group, Value: slog.GroupValue(attrs...)}}
+			}
+			logger.LogAttrs(ctx, level, cfg.Message, attrs...)
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// ClientIP returns the IP of the client of r, taken from the proxy headers
+// if trustProxy is set
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		// The first address of X-Forwarded-For is the original client
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			ip, _, _ := strings.Cut(xff, ",")
+			return strings.TrimSpace(ip)
+		}
+		if ip := r.Header.Get("X-Real-IP"); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// responseWriter records the status code and the number of bytes written
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	written     int64
+	wroteHeader bool
+}
+
+// WriteHeader records the status code
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes written
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,113 @@
+package dbm
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"otel-go-dbm/spanproc"
+)
+
+// AuditEventName is the name of the span event of an audited statement
+const AuditEventName = "db.audit"
+
+// AttrActor is the audit attribute of the actor stored with WithActor
+const AttrActor = attribute.Key("enduser.id")
+
+// Statements changing data, recorded by the audit log
+var mutations = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "TRUNCATE": true,
+}
+
+// WithAudit records every statement changing data (INSERT, UPDATE, DELETE,
+// MERGE and TRUNCATE) to logger and as a db.audit event on the span of the
+// statement: who issued it (see WithActor, and the route and controller of
+// the request), what it changed (operation, table, obfuscated statement,
+// rows affected, error) and when. Give it a logger of its own, e.g. writing
+// to a separate file, so audit records are kept as long as required and
+// are not sampled or filtered with the application logs.
+func WithAudit(logger *slog.Logger) ConnectorOption {
+	return func(o *connectorOptions) {
+		o.auditLogger = logger
+	}
+}
+
+// auditor records the mutations of a connector
+type auditor struct {
+	logger *slog.Logger
+	system attribute.KeyValue
+}
+
+// newAuditor returns the auditor of a connector, or nil when auditing is
+// disabled
+func newAuditor(dialect Dialect, o connectorOptions) *auditor {
+	if o.auditLogger == nil {
+		return nil
+	}
+	system := semconv.DBSystemPostgreSQL
+	switch dialect {
+	case DialectMySQL:
+		system = semconv.DBSystemMySQL
+	case DialectSQLServer:
+		system = semconv.DBSystemMSSQL
+	}
+	return &auditor{logger: o.auditLogger, system: system}
+}
+
+// record records query if it changes data. rows is the number of rows
+// affected, or -1 when it is not known.
+func (a *auditor) record(ctx context.Context, query string, rows int64, elapsed time.Duration, err error) {
+	if a == nil {
+		return
+	}
+	operation, table := sqlOperation(query)
+	if !mutations[operation] {
+		return
+	}
+
+	statement := strings.Join(strings.Fields(spanproc.ObfuscateSQL(query)), " ")
+	attrs := []attribute.KeyValue{
+		a.system,
+		AttrOperationName.String(operation),
+		AttrCollectionName.String(table),
+		semconv.DBStatement(statement),
+		AttrQueryFingerprint.String(Fingerprint(statement)),
+		AttrActor.String(ActorFromContext(ctx)),
+		semconv.HTTPRoute(RouteFromContext(ctx)),
+		attribute.String("controller", ControllerFromContext(ctx)),
+		attribute.Float64("duration_ms", durationMs(elapsed)),
+	}
+	if rows >= 0 {
+		attrs = append(attrs, AttrRowsAffected.Int64(rows))
+	}
+	if err != nil {
+		attrs = append(attrs,
+			attribute.String("error.type", errorType(err)),
+			attribute.String("error.message", err.Error()),
+		)
+	}
+
+	// The span of the statement has ended by the time its rows are read, so
+	// the event is added right after it executes
+	if span := trace.SpanFromContext(ctx); span.IsRecording() {
+		span.AddEvent(AuditEventName, trace.WithAttributes(attrs...))
+	}
+
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+	}
+	if !a.logger.Enabled(ctx, level) {
+		return
+	}
+	logAttrs := make([]slog.Attr, len(attrs))
+	for i, kv := range attrs {
+		logAttrs[i] = slog.Any(string(kv.Key), kv.Value.AsInterface())
+	}
+	a.logger.LogAttrs(ctx, level, "Database mutation", logAttrs...)
+}
@@ -0,0 +1,247 @@
+package dbm
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Datadog keys
+const (
+	KeyDBService     = "dddbs"
+	KeyEnv           = "dde"
+	KeyParentService = "ddps"
+	KeyVersion       = "ddpv"
+	KeyPeerHost      = "ddh"
+	KeyPeerDBName    = "dddb"
+	KeyTraceparent   = "traceparent"
+	KeyTracestate    = "tracestate"
+)
+
+// sqlcommenter standard keys
+const (
+	KeyRoute       = "route"
+	KeyController  = "controller"
+	KeyFramework   = "framework"
+	KeyApplication = "application"
+)
+
+// KeyActor carries the actor of the query (see WithActor)
+const KeyActor = "actor"
+
+// Dialect selects database-specific comment syntax
+type Dialect int
+
+const (
+	// DialectPostgres emits /*key='value'*/
+	DialectPostgres Dialect = iota
+	// DialectMySQL emits /* key='value' */ so the comment can never be read
+	// as a MySQL executable comment (/*!) or optimizer hint (/*+)
+	DialectMySQL
+	// DialectSQLServer emits /*key='value'*/ and additionally sets
+	// CONTEXT_INFO to the traceparent, because SQL Server strips comments
+	// from the plan cache
+	DialectSQLServer
+)
+
+// CommenterConfig holds configuration for Commenter
+type CommenterConfig struct {
+	ServiceName   string
+	DBServiceName string
+	Env           string
+	Version       string
+
+	// PeerHost and PeerDBName are the database host and database name the
+	// queries are sent to
+	PeerHost   string
+	PeerDBName string
+
+	// Values for the sqlcommenter standard keys
+	Application string
+	Framework   string
+
+	// Toggles for the sqlcommenter standard keys
+	EnableRoute       bool
+	EnableController  bool
+	EnableFramework   bool
+	EnableApplication bool
+
+	// EnableActor adds the actor of the query (see WithActor), so DBM can
+	// attribute queries to the calling user or client. Enable it only when
+	// actors are not personal data, as comments reach the database logs.
+	EnableActor bool
+
+	// Validation controls how tags the sqlcommenter spec forbids are handled
+	Validation ValidationMode
+
+	// Dialect selects the comment syntax of the target database
+	Dialect Dialect
+
+	// RecordComment also sets the injected comment as the db.sql.comment
+	// attribute of the query span, e.g. to check comments in a console
+	// exporter without a database log
+	RecordComment bool
+}
+
+// Commenter prepends sqlcommenter-formatted comments to SQL queries
+type Commenter struct {
+	config CommenterConfig
+}
+
+// NewCommenter creates a new Commenter
+func NewCommenter(config *CommenterConfig) *Commenter {
+	var cfg CommenterConfig
+	if config != nil {
+		cfg = *config
+	}
+	if cfg.DBServiceName == "" {
+		cfg.DBServiceName = cfg.ServiceName
+	}
+	if cfg.Application == "" {
+		cfg.Application = cfg.ServiceName
+	}
+
+	return &Commenter{config: cfg}
+}
+
+// WithPeer returns a copy of c that tags comments with the database host
+// and name, for sending the same comments to several hosts such as read
+// replicas
+func (c *Commenter) WithPeer(host, dbName string) *Commenter {
+	cfg := c.config
+	cfg.PeerHost = host
+	cfg.PeerDBName = dbName
+	return &Commenter{config: cfg}
+}
+
+// Inject prepends the comment built from ctx to query.
+// The query is returned unchanged when there is no recording span in ctx
+// or ctx was created by WithoutComment.
+func (c *Commenter) Inject(ctx context.Context, query string) string {
+	if IsCommentDisabled(ctx) {
+		return query
+	}
+
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return query
+	}
+
+	comment := c.Comment(ctx)
+	if comment == "" {
+		return query
+	}
+	if c.config.RecordComment {
+		span.SetAttributes(AttrComment.String(comment))
+	}
+	return comment + " " + query
+}
+
+// Comment builds the SQL comment for the span and request metadata in ctx
+func (c *Commenter) Comment(ctx context.Context) string {
+	tags := c.tags(ctx)
+	if !c.validate(ctx, tags) || len(tags) == 0 {
+		return ""
+	}
+
+	// Keys are sorted as the sqlcommenter spec requires
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s='%s'", k, tags[k]))
+	}
+	if c.config.Dialect == DialectMySQL {
+		return "/* " + strings.Join(parts, ",") + " */"
+	}
+	return "/*" + strings.Join(parts, ",") + "*/"
+}
+
+// tags collects the escaped tag values keyed by tag key
+func (c *Commenter) tags(ctx context.Context) map[string]string {
+	tags := make(map[string]string)
+	// Values are URL-encoded as the sqlcommenter spec describes, so neither
+	// configured values nor free-form ones such as URL paths can close the
+	// quotes or the comment (a '/' is encoded, so no "*/" can appear)
+	add := func(key, value string) {
+		if value != "" {
+			tags[key] = escapeValue(url.PathEscape(value))
+		}
+	}
+
+	add(KeyDBService, c.config.DBServiceName)
+	add(KeyEnv, c.config.Env)
+	add(KeyParentService, c.config.ServiceName)
+	add(KeyVersion, c.config.Version)
+	add(KeyPeerHost, c.config.PeerHost)
+	add(KeyPeerDBName, c.config.PeerDBName)
+
+	// Per-request tags are left out in service mode
+	if !IsServiceComment(ctx) {
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			add(KeyTraceparent, traceparent(sc))
+			// Carries the dd sampling priority so DBM honors the sampling decision
+			add(KeyTracestate, sc.TraceState().String())
+		}
+		if c.config.EnableRoute {
+			add(KeyRoute, RouteFromContext(ctx))
+		}
+		if c.config.EnableController {
+			add(KeyController, ControllerFromContext(ctx))
+		}
+		if c.config.EnableActor {
+			add(KeyActor, ActorFromContext(ctx))
+		}
+	}
+	if c.config.EnableFramework {
+		add(KeyFramework, c.config.Framework)
+	}
+	if c.config.EnableApplication {
+		add(KeyApplication, c.config.Application)
+	}
+
+	return tags
+}
+
+// validate applies the validation mode to tags, dropping invalid tags in place.
+// It returns false when the comment must be omitted entirely.
+func (c *Commenter) validate(ctx context.Context, tags map[string]string) bool {
+	if c.config.Validation == ValidationOff {
+		return true
+	}
+
+	for key, value := range tags {
+		err := validateTag(key, value)
+		if err == nil {
+			continue
+		}
+		if c.config.Validation == ValidationReject {
+			slog.WarnContext(ctx, "Rejected SQL comment with invalid tag", "key", key, "error", err)
+			return false
+		}
+		slog.WarnContext(ctx, "Dropped invalid SQL comment tag", "key", key, "error", err)
+		delete(tags, key)
+	}
+	return true
+}
+
+// traceparent formats sc as a W3C traceparent header value
+func traceparent(sc trace.SpanContext) string {
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags())
+}
+
+// escapeValue escapes backslashes and single quotes in a tag value as the
+// sqlcommenter spec requires, so a trailing backslash cannot escape the
+// closing quote
+func escapeValue(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "'", "\\'").Replace(s)
+}
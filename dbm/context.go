@@ -0,0 +1,75 @@
+package dbm
+
+import "context"
+
+type contextKey int
+
+const (
+	routeKey contextKey = iota
+	controllerKey
+	withoutCommentKey
+	serviceCommentKey
+	queryEventsKey
+	actorKey
+)
+
+// WithRoute returns a copy of ctx carrying the route that issued the query
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey, route)
+}
+
+// RouteFromContext returns the route stored in ctx, or "" if none is set
+func RouteFromContext(ctx context.Context) string {
+	route, _ := ctx.Value(routeKey).(string)
+	return route
+}
+
+// WithController returns a copy of ctx carrying the controller that issued the query
+func WithController(ctx context.Context, controller string) context.Context {
+	return context.WithValue(ctx, controllerKey, controller)
+}
+
+// ControllerFromContext returns the controller stored in ctx, or "" if none is set
+func ControllerFromContext(ctx context.Context) string {
+	controller, _ := ctx.Value(controllerKey).(string)
+	return controller
+}
+
+// WithActor returns a copy of ctx carrying the user or client on whose
+// behalf queries are issued, recorded by the audit log (see WithAudit) and
+// optionally in the SQL comment (see CommenterConfig.EnableActor)
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// ActorFromContext returns the actor stored in ctx, or "" if none is set
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey).(string)
+	return actor
+}
+
+// WithoutComment returns a copy of ctx that disables comment injection for
+// queries issued with it, e.g. hot-path or admin queries
+func WithoutComment(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withoutCommentKey, true)
+}
+
+// IsCommentDisabled reports whether comment injection is disabled for ctx
+func IsCommentDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(withoutCommentKey).(bool)
+	return disabled
+}
+
+// WithServiceComment returns a copy of ctx that limits the comment of
+// queries issued with it to the static service tags, leaving out the
+// traceparent, route and controller. The query text then stays the same
+// across requests, which a prepared statement reused by many requests needs.
+func WithServiceComment(ctx context.Context) context.Context {
+	return context.WithValue(ctx, serviceCommentKey, true)
+}
+
+// IsServiceComment reports whether ctx limits comments to the service tags
+func IsServiceComment(ctx context.Context) bool {
+	service, _ := ctx.Value(serviceCommentKey).(bool)
+	return service
+}
@@ -0,0 +1,268 @@
+package dbm
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/hex"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span attributes of the number of rows a query returned and a statement
+// changed. database/sql ends the query span before the rows are read, so
+// the returned rows are recorded by the code scanning them.
+const (
+	AttrReturnedRows = attribute.Key("db.response.returned_rows")
+	AttrRowsAffected = attribute.Key("db.rows_affected")
+)
+
+// AttrComment is the span attribute of the injected comment, set when
+// CommenterConfig.RecordComment is enabled
+const AttrComment = attribute.Key("db.sql.comment")
+
+// commentedConnector wraps a driver.Connector so every query sent through its
+// connections carries the commenter's SQL comment
+type commentedConnector struct {
+	driver.Connector
+	commenter *Commenter
+	options   connectorOptions
+	explainer *explainer
+	metrics   *operationMetrics
+	auditor   *auditor
+}
+
+// NewConnector wraps c so queries are commented with commenter.
+//
+// Wrap the result with otelsql (otelsql.OpenDB) so the span otelsql creates
+// for each query is already in the context when the comment is built, and
+// the traceparent points at that span rather than its parent.
+func NewConnector(c driver.Connector, commenter *Commenter, opts ...ConnectorOption) driver.Connector {
+	cc := &commentedConnector{Connector: c, commenter: commenter}
+	for _, opt := range opts {
+		opt(&cc.options)
+	}
+	cc.explainer = newExplainer(c, commenter.config.Dialect, cc.options)
+	cc.metrics = newOperationMetrics(commenter.config.Dialect, cc.options)
+	cc.auditor = newAuditor(commenter.config.Dialect, cc.options)
+	return cc
+}
+
+// Connect returns a commenting connection
+func (c *commentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	start := time.Now()
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.setServerTimeout(ctx, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &commentedConn{
+		Conn:            conn,
+		commenter:       c.commenter,
+		timeout:         c.options.statementTimeout,
+		explainer:       c.explainer,
+		metrics:         c.metrics,
+		auditor:         c.auditor,
+		connectDuration: time.Since(start),
+	}, nil
+}
+
+// commentedConn injects comments into queries before passing them to the wrapped connection
+type commentedConn struct {
+	driver.Conn
+	commenter *Commenter
+	timeout   time.Duration
+	explainer *explainer
+	metrics   *operationMetrics
+	auditor   *auditor
+
+	// For the lifecycle events: the time Connect took and when the last
+	// statement finished (zero before the first one)
+	connectDuration time.Duration
+	lastUsed        time.Time
+}
+
+var (
+	_ driver.ConnBeginTx        = (*commentedConn)(nil)
+	_ driver.ConnPrepareContext = (*commentedConn)(nil)
+	_ driver.QueryerContext     = (*commentedConn)(nil)
+	_ driver.ExecerContext      = (*commentedConn)(nil)
+	_ driver.Pinger             = (*commentedConn)(nil)
+	_ driver.SessionResetter    = (*commentedConn)(nil)
+	_ driver.Validator          = (*commentedConn)(nil)
+	_ driver.NamedValueChecker  = (*commentedConn)(nil)
+)
+
+// PrepareContext comments the query before preparing it
+func (c *commentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := c.setContextInfo(ctx); err != nil {
+		return nil, err
+	}
+	commented := c.commenter.Inject(ctx, query)
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, commented)
+	} else {
+		stmt, err = c.Conn.Prepare(commented)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &commentedStmt{Stmt: stmt, conn: c, query: query}, nil
+}
+
+// QueryContext comments the query before running it
+func (c *commentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := c.setContextInfo(ctx); err != nil {
+		return nil, err
+	}
+	commented := c.commenter.Inject(ctx, query)
+	return c.runQuery(ctx, query, args, func(ctx context.Context) (driver.Rows, error) {
+		return q.QueryContext(ctx, commented, args)
+	})
+}
+
+// ExecContext comments the query before running it
+func (c *commentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := c.setContextInfo(ctx); err != nil {
+		return nil, err
+	}
+	commented := c.commenter.Inject(ctx, query)
+	return c.runExec(ctx, query, args, func(ctx context.Context) (driver.Result, error) {
+		return e.ExecContext(ctx, commented, args)
+	})
+}
+
+// runQuery runs query under the statement timeout, records its lifecycle
+// events and hands its duration, measured until the rows are closed, to the
+// slow query explainer and the operation metrics. Mutations returning rows,
+// e.g. INSERT ... RETURNING, are audited once they execute.
+func (c *commentedConn) runQuery(ctx context.Context, query string, args []driver.NamedValue, run func(context.Context) (driver.Rows, error)) (driver.Rows, error) {
+	lc := c.startLifecycle(ctx)
+	start := time.Now()
+	rows, err := queryWithTimeout(ctx, c.timeout, run)
+	lc.executed()
+	c.auditor.record(ctx, query, -1, time.Since(start), err)
+	if err != nil {
+		c.lastUsed = time.Now()
+		c.explainer.observe(ctx, query, args, time.Since(start))
+		c.metrics.record(ctx, query, time.Since(start), err)
+		return nil, err
+	}
+	if lc == nil && c.explainer == nil && c.metrics == nil {
+		c.lastUsed = time.Now()
+		return rows, nil
+	}
+
+	hooked := &hookRows{Rows: rows, onClose: func() {
+		c.lastUsed = time.Now()
+		lc.scanned()
+		c.explainer.observe(ctx, query, args, time.Since(start))
+		c.metrics.record(ctx, query, time.Since(start), nil)
+	}}
+	if lc != nil {
+		hooked.onNext = lc.next
+	}
+	return hooked, nil
+}
+
+// runExec runs query under the statement timeout, records its lifecycle
+// events, hands its duration to the slow query explainer and the operation
+// metrics, records the rows affected on the span of ctx and audits the
+// mutations
+func (c *commentedConn) runExec(ctx context.Context, query string, args []driver.NamedValue, run func(context.Context) (driver.Result, error)) (driver.Result, error) {
+	lc := c.startLifecycle(ctx)
+	start := time.Now()
+	result, err := execWithTimeout(ctx, c.timeout, run)
+	lc.executed()
+	c.lastUsed = time.Now()
+	c.explainer.observe(ctx, query, args, time.Since(start))
+	c.metrics.record(ctx, query, time.Since(start), err)
+	rows := int64(-1)
+	if err == nil {
+		if n, err := result.RowsAffected(); err == nil {
+			trace.SpanFromContext(ctx).SetAttributes(AttrRowsAffected.Int64(n))
+			rows = n
+		}
+	}
+	c.auditor.record(ctx, query, rows, time.Since(start), err)
+	return result, err
+}
+
+// setContextInfo stores the traceparent in the session's CONTEXT_INFO so
+// SQL Server monitoring can correlate the query with its span.
+// It does nothing for other dialects or when comments are disabled or
+// limited to the service tags.
+func (c *commentedConn) setContextInfo(ctx context.Context) error {
+	if c.commenter.config.Dialect != DialectSQLServer || IsCommentDisabled(ctx) || IsServiceComment(ctx) {
+		return nil
+	}
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil
+	}
+
+	// CONTEXT_INFO holds up to 128 bytes; the traceparent is 55
+	info := hex.EncodeToString([]byte(traceparent(sc)))
+	_, err := e.ExecContext(ctx, "SET CONTEXT_INFO 0x"+info, nil)
+	return err
+}
+
+// BeginTx starts a transaction on the wrapped connection
+func (c *commentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+// Ping pings the wrapped connection if it supports it
+func (c *commentedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession resets the wrapped connection if it supports it
+func (c *commentedConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid reports whether the wrapped connection is still usable
+func (c *commentedConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// CheckNamedValue delegates argument conversion to the wrapped connection
+func (c *commentedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
@@ -0,0 +1,23 @@
+// Package entdbm opens Ent drivers whose queries are traced by otelsql and
+// commented by dbm, the same as the database/sql path.
+package entdbm
+
+import (
+	"database/sql/driver"
+
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/XSAM/otelsql"
+
+	"otel-go-dbm/dbm"
+)
+
+// Open wraps c with comment injection and otelsql and returns it as an Ent
+// driver for dialect, e.g. dialect.Postgres.
+//
+// Pass the result to the generated client with ent.Driver. Pass a
+// db.system attribute (e.g. semconv.DBSystemPostgreSQL) in opts so the
+// span processor types the spans as span.type=sql.
+func Open(dialect string, c driver.Connector, commenter *dbm.Commenter, opts ...otelsql.Option) *entsql.Driver {
+	db := otelsql.OpenDB(dbm.NewConnector(c, commenter), opts...)
+	return entsql.OpenDB(dialect, db)
+}
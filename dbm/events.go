@@ -0,0 +1,113 @@
+package dbm
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queryEvents is the span and start time stored by WithQueryEvents
+type queryEvents struct {
+	span  trace.Span
+	start time.Time
+	used  atomic.Bool
+}
+
+// WithQueryEvents returns a copy of ctx whose next query records its
+// lifecycle as events on the span of ctx: connection acquired, executed,
+// first row and scanned. Call it right before the query; the time until the
+// driver receives the query is reported as connection pool wait.
+//
+// Without it, acquisition and execution are recorded on the span of the
+// query itself. database/sql ends that span before the rows are read, so
+// the row events need a span that stays open while scanning.
+func WithQueryEvents(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryEventsKey, &queryEvents{
+		span:  trace.SpanFromContext(ctx),
+		start: time.Now(),
+	})
+}
+
+// lifecycle records the phases of one statement as span events
+type lifecycle struct {
+	span       trace.Span
+	start      time.Time
+	executedAt time.Time
+	rows       int64
+}
+
+// startLifecycle records the connection acquired event and returns the
+// lifecycle of the statement, or nil when its span is not recording
+func (c *commentedConn) startLifecycle(ctx context.Context) *lifecycle {
+	now := time.Now()
+	span := trace.SpanFromContext(ctx)
+	var attrs []attribute.KeyValue
+	if ev, ok := ctx.Value(queryEventsKey).(*queryEvents); ok {
+		span = ev.span
+		if !ev.used.Swap(true) {
+			attrs = append(attrs, attribute.Float64("db.connection.wait_ms", durationMs(now.Sub(ev.start))))
+		}
+	}
+	if !span.IsRecording() {
+		return nil
+	}
+
+	if c.lastUsed.IsZero() {
+		attrs = append(attrs,
+			attribute.Bool("db.connection.reused", false),
+			attribute.Float64("db.connection.connect_ms", durationMs(c.connectDuration)),
+		)
+	} else {
+		attrs = append(attrs,
+			attribute.Bool("db.connection.reused", true),
+			attribute.Float64("db.connection.idle_ms", durationMs(now.Sub(c.lastUsed))),
+		)
+	}
+	span.AddEvent("db.connection.acquired", trace.WithTimestamp(now), trace.WithAttributes(attrs...))
+	return &lifecycle{span: span, start: now}
+}
+
+// executed records the executed event when the driver returns the result
+func (l *lifecycle) executed() {
+	if l == nil {
+		return
+	}
+	l.executedAt = time.Now()
+	l.span.AddEvent("db.query.executed", trace.WithTimestamp(l.executedAt), trace.WithAttributes(
+		attribute.Float64("db.query.duration_ms", durationMs(l.executedAt.Sub(l.start))),
+	))
+}
+
+// next counts a row read by Next and records the first row event
+func (l *lifecycle) next(err error) {
+	if err != nil {
+		return
+	}
+	l.rows++
+	if l.rows == 1 {
+		now := time.Now()
+		l.span.AddEvent("db.rows.first_row", trace.WithTimestamp(now), trace.WithAttributes(
+			attribute.Float64("db.query.first_row_ms", durationMs(now.Sub(l.start))),
+		))
+	}
+}
+
+// scanned records the scanned event when the rows are closed
+func (l *lifecycle) scanned() {
+	if l == nil {
+		return
+	}
+	now := time.Now()
+	l.span.AddEvent("db.rows.scanned", trace.WithTimestamp(now), trace.WithAttributes(
+		AttrReturnedRows.Int64(l.rows),
+		attribute.Float64("db.rows.scan_ms", durationMs(now.Sub(l.executedAt))),
+	))
+}
+
+// durationMs returns d in fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
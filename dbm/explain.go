@@ -0,0 +1,144 @@
+package dbm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Time allowed for one EXPLAIN
+const explainTimeout = 10 * time.Second
+
+// WithExplain runs EXPLAIN in the background for statements that take at
+// least threshold, and records the plan on a db.explain span below the
+// statement's span. Plans longer than maxSize bytes are truncated.
+//
+// Only PostgreSQL (EXPLAIN (FORMAT JSON)) and MySQL (EXPLAIN FORMAT=JSON)
+// are supported. EXPLAIN is run without ANALYZE on a separate connection, so
+// the statement is never executed again. At most one EXPLAIN runs at a time;
+// slow statements seen meanwhile are not explained.
+func WithExplain(threshold time.Duration, maxSize int) ConnectorOption {
+	return func(o *connectorOptions) {
+		o.explainThreshold = threshold
+		o.explainMaxSize = maxSize
+	}
+}
+
+// explainer explains slow statements on its own uninstrumented connection
+type explainer struct {
+	db        *sql.DB
+	prefix    string
+	threshold time.Duration
+	maxSize   int
+	busy      chan struct{}
+	tracer    trace.Tracer
+}
+
+// newExplainer returns an explainer for the connections of c, or nil when
+// explaining is disabled or the dialect has no JSON EXPLAIN
+func newExplainer(c driver.Connector, dialect Dialect, o connectorOptions) *explainer {
+	if o.explainThreshold <= 0 {
+		return nil
+	}
+	var prefix string
+	switch dialect {
+	case DialectPostgres:
+		prefix = "EXPLAIN (FORMAT JSON) "
+	case DialectMySQL:
+		prefix = "EXPLAIN FORMAT=JSON "
+	default:
+		return nil
+	}
+	if o.explainMaxSize <= 0 {
+		o.explainMaxSize = 4096
+	}
+
+	db := sql.OpenDB(c)
+	db.SetMaxOpenConns(1)
+	return &explainer{
+		db:        db,
+		prefix:    prefix,
+		threshold: o.explainThreshold,
+		maxSize:   o.explainMaxSize,
+		busy:      make(chan struct{}, 1),
+		tracer:    otel.GetTracerProvider().Tracer(instrumentationName),
+	}
+}
+
+// observe explains query in the background when elapsed reaches the
+// threshold. ctx is the context of the statement.
+func (e *explainer) observe(ctx context.Context, query string, args []driver.NamedValue, elapsed time.Duration) {
+	if e == nil || elapsed < e.threshold || !explainable(query) {
+		return
+	}
+	select {
+	case e.busy <- struct{}{}:
+	default:
+		return
+	}
+
+	values := make([]any, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			values[i] = sql.Named(arg.Name, arg.Value)
+		} else {
+			values[i] = arg.Value
+		}
+	}
+
+	// The request may finish before EXPLAIN does
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer func() { <-e.busy }()
+		e.explain(ctx, query, values, elapsed)
+	}()
+}
+
+// explain runs EXPLAIN for query inside a db.explain span
+func (e *explainer) explain(ctx context.Context, query string, args []any, elapsed time.Duration) {
+	ctx, span := e.tracer.Start(ctx, "db.explain", trace.WithAttributes(
+		attribute.Int64("db.query.duration_ms", elapsed.Milliseconds()),
+		attribute.Int64("db.explain.threshold_ms", e.threshold.Milliseconds()),
+	))
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, explainTimeout)
+	defer cancel()
+
+	var plan string
+	if err := e.db.QueryRowContext(ctx, e.prefix+query, args...).Scan(&plan); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+
+	truncated := len(plan) > e.maxSize
+	if truncated {
+		plan = strings.ToValidUTF8(plan[:e.maxSize], "")
+	}
+	span.SetAttributes(
+		attribute.String("db.plan", plan),
+		attribute.String("db.plan.format", "json"),
+		attribute.Bool("db.plan.truncated", truncated),
+	)
+}
+
+// explainable reports whether query is a statement EXPLAIN accepts
+func explainable(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "WITH", "INSERT", "UPDATE", "DELETE":
+		return true
+	}
+	return false
+}
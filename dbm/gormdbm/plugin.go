@@ -0,0 +1,170 @@
+// Package gormdbm is a GORM plugin that traces queries and adds Datadog SQL
+// comments, matching the spans and comments of the database/sql path.
+package gormdbm
+
+import (
+	"context"
+	"database/sql"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+
+	"otel-go-dbm/dbm"
+)
+
+const (
+	instrumentationName = "otel-go-dbm/dbm/gormdbm"
+
+	// Keys used to carry state from the before to the after callback
+	spanKey     = "gormdbm:span"
+	connPoolKey = "gormdbm:conn_pool"
+
+	// commitCallback commits or rolls back the default transaction of writes
+	commitCallback = "gorm:commit_or_rollback_transaction"
+)
+
+// Plugin traces and comments GORM queries.
+//
+// Use it with a plain database connection. A *sql.DB that is already wrapped
+// by otelsql and dbm.NewConnector would produce a second span and comment.
+type Plugin struct {
+	tracer    trace.Tracer
+	commenter *dbm.Commenter
+	attrs     []attribute.KeyValue
+}
+
+var _ gorm.Plugin = (*Plugin)(nil)
+
+// NewPlugin creates a new Plugin.
+// attrs are added to every span, e.g. semconv.DBSystemPostgreSQL.
+func NewPlugin(commenter *dbm.Commenter, attrs ...attribute.KeyValue) *Plugin {
+	return &Plugin{
+		tracer:    otel.GetTracerProvider().Tracer(instrumentationName),
+		commenter: commenter,
+		attrs:     attrs,
+	}
+}
+
+// Name returns the plugin name
+func (p *Plugin) Name() string {
+	return "otel-go-dbm"
+}
+
+// registerer is satisfied by the callbacks returned from Before and After
+type registerer interface {
+	Register(name string, fn func(*gorm.DB)) error
+}
+
+// Initialize registers the callbacks around each GORM operation
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, h := range []struct {
+		name      string
+		operation string
+		before    registerer
+		after     registerer
+	}{
+		// The writes restore the connection pool before the transaction of
+		// SkipDefaultTransaction=false is committed, as the commit needs the
+		// *sql.Tx in Statement.ConnPool
+		{"create", "INSERT", cb.Create().Before("gorm:create"), cb.Create().After("gorm:create").Before(commitCallback)},
+		{"query", "SELECT", cb.Query().Before("gorm:query"), cb.Query().After("gorm:query")},
+		{"update", "UPDATE", cb.Update().Before("gorm:update"), cb.Update().After("gorm:update").Before(commitCallback)},
+		{"delete", "DELETE", cb.Delete().Before("gorm:delete"), cb.Delete().After("gorm:delete").Before(commitCallback)},
+		{"row", "", cb.Row().Before("gorm:row"), cb.Row().After("gorm:row")},
+		{"raw", "", cb.Raw().Before("gorm:raw"), cb.Raw().After("gorm:raw")},
+	} {
+		if err := h.before.Register("otel-go-dbm:before_"+h.name, p.before(h.name, h.operation)); err != nil {
+			return err
+		}
+		if err := h.after.Register("otel-go-dbm:after_"+h.name, p.after(h.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// before starts the span and swaps in a commenting connection pool
+func (p *Plugin) before(name, operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		attrs := make([]attribute.KeyValue, 0, len(p.attrs)+2)
+		if operation != "" {
+			attrs = append(attrs, semconv.DBOperation(operation))
+		}
+		if db.Statement.Table != "" {
+			attrs = append(attrs, semconv.DBSQLTable(db.Statement.Table))
+		}
+		attrs = append(attrs, p.attrs...)
+
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx, span := p.tracer.Start(ctx, "gorm."+name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attrs...),
+		)
+		db.Statement.Context = ctx
+		db.InstanceSet(spanKey, span)
+
+		db.InstanceSet(connPoolKey, db.Statement.ConnPool)
+		db.Statement.ConnPool = &commentedConnPool{ConnPool: db.Statement.ConnPool, commenter: p.commenter}
+	}
+}
+
+// after restores the connection pool and ends the span. RowsAffected holds
+// the rows scanned for queries and the rows changed otherwise; it is not
+// known for Row, whose rows are scanned by the caller.
+func (p *Plugin) after(name string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if pool, ok := db.InstanceGet(connPoolKey); ok {
+			db.Statement.ConnPool = pool.(gorm.ConnPool)
+		}
+
+		v, ok := db.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+		span := v.(trace.Span)
+		defer span.End()
+
+		span.SetAttributes(semconv.DBStatement(db.Statement.SQL.String()))
+		switch name {
+		case "query":
+			span.SetAttributes(dbm.AttrReturnedRows.Int64(db.Statement.RowsAffected))
+		case "row":
+		default:
+			span.SetAttributes(dbm.AttrRowsAffected.Int64(db.Statement.RowsAffected))
+		}
+		if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+			span.RecordError(db.Error)
+			span.SetStatus(codes.Error, db.Error.Error())
+		}
+	}
+}
+
+// commentedConnPool comments queries before passing them to the wrapped pool
+type commentedConnPool struct {
+	gorm.ConnPool
+	commenter *dbm.Commenter
+}
+
+func (c *commentedConnPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return c.ConnPool.PrepareContext(ctx, c.commenter.Inject(ctx, query))
+}
+
+func (c *commentedConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.ConnPool.ExecContext(ctx, c.commenter.Inject(ctx, query), args...)
+}
+
+func (c *commentedConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.ConnPool.QueryContext(ctx, c.commenter.Inject(ctx, query), args...)
+}
+
+func (c *commentedConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return c.ConnPool.QueryRowContext(ctx, c.commenter.Inject(ctx, query), args...)
+}
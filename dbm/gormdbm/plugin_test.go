@@ -0,0 +1,91 @@
+package gormdbm_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"otel-go-dbm/dbm"
+	"otel-go-dbm/dbm/gormdbm"
+)
+
+type user struct {
+	ID   int64
+	Name string
+}
+
+// openMock opens a GORM database on sqlmock with the plugin, using the
+// default transaction around writes. Spans are recorded so queries are
+// commented.
+func openMock(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commenter := dbm.NewCommenter(&dbm.CommenterConfig{ServiceName: "test"})
+	if err := db.Use(gormdbm.NewPlugin(commenter)); err != nil {
+		t.Fatal(err)
+	}
+	return db, mock
+}
+
+func TestWritesCommit(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		query string
+		run   func(db *gorm.DB) error
+		mock  func(mock sqlmock.Sqlmock, query string)
+	}{
+		{
+			name:  "create",
+			query: `INSERT INTO "users"`,
+			run:   func(db *gorm.DB) error { return db.Create(&user{Name: "a"}).Error },
+			mock: func(mock sqlmock.Sqlmock, query string) {
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			},
+		},
+		{
+			name:  "update",
+			query: `UPDATE "users"`,
+			run:   func(db *gorm.DB) error { return db.Model(&user{ID: 1}).Update("name", "b").Error },
+			mock: func(mock sqlmock.Sqlmock, query string) {
+				mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:  "delete",
+			query: `DELETE FROM "users"`,
+			run:   func(db *gorm.DB) error { return db.Delete(&user{ID: 1}).Error },
+			mock: func(mock sqlmock.Sqlmock, query string) {
+				mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := openMock(t)
+			mock.ExpectBegin()
+			tt.mock(mock, `^/\*.*ddps='test'.*\*/ `+regexp.QuoteMeta(tt.query))
+			mock.ExpectCommit()
+
+			if err := tt.run(db); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
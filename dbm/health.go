@@ -0,0 +1,157 @@
+package dbm
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// HealthConfig holds configuration for HealthMonitor
+type HealthConfig struct {
+	// DB is the database to ping
+	DB *sql.DB
+
+	// Role is recorded as db.role on the gauge, e.g. primary or replica
+	Role string
+
+	// Interval is the time between pings. Defaults to 10s.
+	Interval time.Duration
+
+	// Timeout bounds each ping. Defaults to 2s.
+	Timeout time.Duration
+
+	// FailureThreshold is the number of consecutive failed pings after which
+	// the database is reported unhealthy. Defaults to 3.
+	FailureThreshold int
+
+	// MaxIdleConns is the pool's idle connection limit, restored after the
+	// idle connections are dropped on a failed ping. Set it to the value
+	// passed to sql.DB.SetMaxIdleConns; 0 keeps no idle connections, and a
+	// negative value restores the database/sql default of 2.
+	MaxIdleConns int
+}
+
+// HealthMonitor pings a database in the background and reports whether it
+// is reachable, for readiness probes and the db.client.health gauge.
+//
+// When a ping fails the idle connections are closed, so the next ping and
+// the next queries dial new connections instead of reusing ones broken by a
+// failover or a network change.
+type HealthMonitor struct {
+	config   HealthConfig
+	healthy  atomic.Bool
+	failures int
+	lastErr  atomic.Pointer[string]
+}
+
+// NewHealthMonitor creates a HealthMonitor that reports healthy until the
+// first failed pings
+func NewHealthMonitor(config *HealthConfig) *HealthMonitor {
+	cfg := *config
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	if cfg.FailureThreshold < 1 {
+		cfg.FailureThreshold = 3
+	}
+	if cfg.MaxIdleConns < 0 {
+		cfg.MaxIdleConns = 2
+	}
+
+	m := &HealthMonitor{config: cfg}
+	m.healthy.Store(true)
+
+	_, err := otel.GetMeterProvider().Meter(instrumentationName).Int64ObservableGauge("db.client.health",
+		metric.WithDescription("Whether the database answers pings (1) or not (0)"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			var v int64
+			if m.healthy.Load() {
+				v = 1
+			}
+			o.Observe(v, metric.WithAttributes(attribute.String("db.role", cfg.Role)))
+			return nil
+		}),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	return m
+}
+
+// Run pings the database every Interval until ctx is done
+func (m *HealthMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.check(ctx)
+		}
+	}
+}
+
+// Healthy reports whether the last pings succeeded
+func (m *HealthMonitor) Healthy() bool {
+	return m.healthy.Load()
+}
+
+// Role returns the role the monitor was configured with
+func (m *HealthMonitor) Role() string {
+	return m.config.Role
+}
+
+// Err returns the error of the last failed ping, or "" after a success
+func (m *HealthMonitor) Err() string {
+	if err := m.lastErr.Load(); err != nil {
+		return *err
+	}
+	return ""
+}
+
+// check pings the database once and updates the health state
+func (m *HealthMonitor) check(ctx context.Context) {
+	pingCtx, cancel := context.WithTimeout(ctx, m.config.Timeout)
+	err := m.config.DB.PingContext(pingCtx)
+	cancel()
+	if ctx.Err() != nil {
+		return
+	}
+
+	if err == nil {
+		m.failures = 0
+		m.lastErr.Store(nil)
+		if !m.healthy.Swap(true) {
+			slog.InfoContext(ctx, "Database is reachable again", "role", m.config.Role)
+		}
+		return
+	}
+
+	msg := err.Error()
+	m.lastErr.Store(&msg)
+	m.failures++
+	slog.WarnContext(ctx, "Database health check failed",
+		"role", m.config.Role,
+		"consecutive_failures", m.failures,
+		"error", err,
+	)
+
+	// Drop the idle connections so the next ping dials a new one
+	m.config.DB.SetMaxIdleConns(0)
+	m.config.DB.SetMaxIdleConns(m.config.MaxIdleConns)
+
+	if m.failures >= m.config.FailureThreshold && m.healthy.Swap(false) {
+		slog.ErrorContext(ctx, "Database is unreachable", "role", m.config.Role, "error", err)
+	}
+}
@@ -0,0 +1,245 @@
+package dbm
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+
+	"otel-go-dbm/spanproc"
+)
+
+// Metric attributes of the statement, named after the current database
+// semantic conventions
+const (
+	AttrOperationName    = attribute.Key("db.operation.name")
+	AttrCollectionName   = attribute.Key("db.collection.name")
+	AttrQueryFingerprint = attribute.Key("db.query.fingerprint")
+)
+
+// OperationDurationBuckets are the bucket boundaries of
+// db.client.operation.duration in seconds, as recommended by the semantic
+// conventions
+var OperationDurationBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+
+// WithOperationMetrics records the duration of every query and exec as the
+// db.client.operation.duration histogram, labeled with the operation
+// (SELECT, INSERT, ...), the primary table and error.type on failure. Unlike
+// the spans, the histogram is not affected by trace sampling. The duration
+// of a query lasts until its rows are closed.
+func WithOperationMetrics() ConnectorOption {
+	return func(o *connectorOptions) {
+		o.operationMetrics = true
+	}
+}
+
+// WithSlowQueryCounter counts the queries and execs that take at least
+// threshold as db.client.slow_queries, labeled with the route of the
+// request (see WithRoute) and the fingerprint of the statement, so slow
+// queries can be alerted on when sampling drops their traces. The first
+// slow execution of each fingerprint is logged with the obfuscated
+// statement to look the fingerprint up.
+func WithSlowQueryCounter(threshold time.Duration) ConnectorOption {
+	return func(o *connectorOptions) {
+		o.slowQueryThreshold = threshold
+	}
+}
+
+// operationMetrics records the duration of the statements of a connector
+// and counts the slow ones
+type operationMetrics struct {
+	system   attribute.KeyValue
+	duration metric.Float64Histogram
+
+	slowThreshold time.Duration
+	slow          metric.Int64Counter
+	seen          sync.Map // fingerprints already logged as slow
+}
+
+// newOperationMetrics returns the metrics of a connector, or nil when they
+// are disabled
+func newOperationMetrics(dialect Dialect, o connectorOptions) *operationMetrics {
+	if !o.operationMetrics && o.slowQueryThreshold <= 0 {
+		return nil
+	}
+	system := semconv.DBSystemPostgreSQL
+	switch dialect {
+	case DialectMySQL:
+		system = semconv.DBSystemMySQL
+	case DialectSQLServer:
+		system = semconv.DBSystemMSSQL
+	}
+
+	m := &operationMetrics{system: system, slowThreshold: o.slowQueryThreshold}
+	meter := otel.GetMeterProvider().Meter(instrumentationName)
+	var err error
+	if o.operationMetrics {
+		if m.duration, err = meter.Float64Histogram("db.client.operation.duration",
+			metric.WithDescription("Duration of database client operations"),
+			metric.WithUnit("s"),
+			metric.WithExplicitBucketBoundaries(OperationDurationBuckets...),
+		); err != nil {
+			otel.Handle(err)
+		}
+	}
+	if o.slowQueryThreshold > 0 {
+		if m.slow, err = meter.Int64Counter("db.client.slow_queries",
+			metric.WithDescription("Number of database operations that took at least the slow query threshold"),
+			metric.WithUnit("{query}"),
+		); err != nil {
+			otel.Handle(err)
+		}
+	}
+	return m
+}
+
+// record records the duration of query and whether it failed, and counts
+// it when it is slow
+func (m *operationMetrics) record(ctx context.Context, query string, elapsed time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	if m.duration != nil {
+		operation, table := sqlOperation(query)
+		attrs := []attribute.KeyValue{m.system, AttrOperationName.String(operation)}
+		if table != "" {
+			attrs = append(attrs, AttrCollectionName.String(table))
+		}
+		if err != nil {
+			attrs = append(attrs, attribute.String("error.type", errorType(err)))
+		}
+		m.duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(attrs...))
+	}
+	if m.slow != nil && elapsed >= m.slowThreshold {
+		m.recordSlow(ctx, query, elapsed)
+	}
+}
+
+// recordSlow counts a slow query and logs its fingerprint the first time
+func (m *operationMetrics) recordSlow(ctx context.Context, query string, elapsed time.Duration) {
+	statement := strings.Join(strings.Fields(spanproc.ObfuscateSQL(query)), " ")
+	fingerprint := Fingerprint(statement)
+	attrs := []attribute.KeyValue{m.system, AttrQueryFingerprint.String(fingerprint)}
+	route := RouteFromContext(ctx)
+	if route != "" {
+		attrs = append(attrs, semconv.HTTPRoute(route))
+	}
+	m.slow.Add(ctx, 1, metric.WithAttributes(attrs...))
+
+	if _, logged := m.seen.LoadOrStore(fingerprint, struct{}{}); !logged {
+		slog.WarnContext(ctx, "Slow query",
+			"db.query.fingerprint", fingerprint,
+			"db.statement", statement,
+			"route", route,
+			"duration_ms", durationMs(elapsed),
+			"threshold_ms", durationMs(m.slowThreshold),
+		)
+	}
+}
+
+// Fingerprint returns a short hash identifying an obfuscated statement, the
+// db.query.fingerprint of the slow query counter
+func Fingerprint(statement string) string {
+	h := fnv.New64a()
+	h.Write([]byte(statement))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// Operations reported as db.operation.name; others are reported as OTHER
+// so arbitrary statements do not add label values
+var sqlOperations = map[string]bool{
+	"SELECT": true, "INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true,
+	"WITH": true, "CALL": true, "EXEC": true, "EXECUTE": true,
+	"BEGIN": true, "COMMIT": true, "ROLLBACK": true, "SAVEPOINT": true, "RELEASE": true,
+	"CREATE": true, "ALTER": true, "DROP": true, "TRUNCATE": true,
+	"SET": true, "SHOW": true, "EXPLAIN": true, "LISTEN": true, "NOTIFY": true, "UNLISTEN": true,
+	"VACUUM": true, "ANALYZE": true,
+}
+
+// The keyword before the primary table of each operation
+var tableKeywords = map[string]string{
+	"SELECT": "FROM",
+	"DELETE": "FROM",
+	"INSERT": "INTO",
+	"MERGE":  "INTO",
+	"UPDATE": "UPDATE",
+}
+
+// identifier matches a plain identifier or one quoted with double quotes,
+// backticks or brackets
+const identifier = `(?:[\w$]+|"[^"]+"|` + "`[^`]+`" + `|\[[^\]]+\])`
+
+// tableName matches a possibly schema-qualified table name following a
+// keyword
+var tableName = regexp.MustCompile(`(?i)\b(FROM|INTO|UPDATE)\s+(` + identifier + `(?:\.` + identifier + `)*)`)
+
+// sqlOperation returns the operation of query and its primary table: the
+// first table after FROM for SELECT and DELETE, after INTO for INSERT and
+// MERGE, and the updated table for UPDATE. The table is "" when it is not
+// known, e.g. for a subquery or a CTE.
+func sqlOperation(query string) (operation, table string) {
+	query = stripLeadingComments(query)
+	end := strings.IndexFunc(query, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z')
+	})
+	if end < 0 {
+		end = len(query)
+	}
+	operation = strings.ToUpper(query[:end])
+	if !sqlOperations[operation] {
+		return "OTHER", ""
+	}
+
+	keyword, ok := tableKeywords[operation]
+	if !ok {
+		return operation, ""
+	}
+	// Only keywords outside parentheses belong to the statement itself, not
+	// to subqueries or functions such as EXTRACT(YEAR FROM created_at)
+	depth, pos := 0, 0
+	for _, m := range tableName.FindAllStringSubmatchIndex(query, -1) {
+		depth += strings.Count(query[pos:m[0]], "(") - strings.Count(query[pos:m[0]], ")")
+		pos = m[0]
+		if depth <= 0 && strings.EqualFold(query[m[2]:m[3]], keyword) {
+			return operation, unquoteIdentifier.Replace(query[m[4]:m[5]])
+		}
+	}
+	return operation, ""
+}
+
+// unquoteIdentifier removes the quotes of PostgreSQL, MySQL and SQL Server
+// identifiers
+var unquoteIdentifier = strings.NewReplacer(`"`, "", "`", "", "[", "", "]", "")
+
+// stripLeadingComments removes the whitespace and comments before the
+// first keyword of query
+func stripLeadingComments(query string) string {
+	for {
+		query = strings.TrimLeft(query, " \t\r\n(")
+		switch {
+		case strings.HasPrefix(query, "/*"):
+			end := strings.Index(query, "*/")
+			if end < 0 {
+				return ""
+			}
+			query = query[end+2:]
+		case strings.HasPrefix(query, "--"):
+			end := strings.IndexByte(query, '\n')
+			if end < 0 {
+				return ""
+			}
+			query = query[end+1:]
+		default:
+			return query
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package dbm
+
+import (
+	"net/http"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RouteMiddleware stores the matched route pattern in the request context
+// so the commenter can emit it as the route tag, and sets it as http.route
+// on the server span
+func RouteMiddleware(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace.SpanFromContext(r.Context()).SetAttributes(semconv.HTTPRoute(route))
+		next.ServeHTTP(w, r.WithContext(WithRoute(r.Context(), route)))
+	})
+}
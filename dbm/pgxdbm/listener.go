@@ -0,0 +1,234 @@
+package pgxdbm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NotificationHandler processes one notification. ctx carries the consumer
+// span of the notification.
+type NotificationHandler func(ctx context.Context, n *pgconn.Notification) error
+
+// ListenerConfig holds configuration for Listener
+type ListenerConfig struct {
+	// ConnConfig configures the dedicated LISTEN connection
+	ConnConfig *pgx.ConnConfig
+
+	// BeforeConnect is called with a copy of ConnConfig before each
+	// connection attempt, e.g. to set a fresh IAM token as the password
+	BeforeConnect func(ctx context.Context, cfg *pgx.ConnConfig) error
+
+	// Tracer traces and comments the LISTEN statements. Optional.
+	Tracer *QueryTracer
+
+	// ReconnectDelay is the wait before reconnecting after the connection
+	// is lost. Defaults to 1s.
+	ReconnectDelay time.Duration
+}
+
+// Listener receives PostgreSQL notifications on a dedicated connection and
+// passes each one to the handlers subscribed to its channel, inside a
+// consumer span.
+//
+// When the payload is a JSON object with a traceparent field (see
+// TracePayload), the consumer span continues the trace of the notifying
+// request. Notifications sent while the connection is being re-established
+// are lost, as PostgreSQL only delivers them to connected listeners.
+type Listener struct {
+	config ListenerConfig
+	tracer trace.Tracer
+
+	mu       sync.RWMutex
+	handlers map[string][]NotificationHandler
+}
+
+// NewListener creates a new Listener
+func NewListener(config *ListenerConfig) *Listener {
+	cfg := *config
+	if cfg.ReconnectDelay <= 0 {
+		cfg.ReconnectDelay = time.Second
+	}
+	return &Listener{
+		config:   cfg,
+		tracer:   otel.GetTracerProvider().Tracer(instrumentationName),
+		handlers: make(map[string][]NotificationHandler),
+	}
+}
+
+// Subscribe registers h for notifications on channel.
+// Channels subscribed after Run has started are listened to on the next
+// reconnect.
+func (l *Listener) Subscribe(channel string, h NotificationHandler) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handlers[channel] = append(l.handlers[channel], h)
+}
+
+// Run listens until ctx is done, reconnecting when the connection is lost.
+// It returns ctx.Err().
+func (l *Listener) Run(ctx context.Context) error {
+	for {
+		err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		slog.WarnContext(ctx, "LISTEN connection lost, reconnecting",
+			"error", err,
+			"retry_in", l.config.ReconnectDelay.String(),
+		)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(l.config.ReconnectDelay):
+		}
+	}
+}
+
+// listen connects, listens to the subscribed channels and dispatches
+// notifications until the connection fails or ctx is done
+func (l *Listener) listen(ctx context.Context) error {
+	cfg := l.config.ConnConfig.Copy()
+	if l.config.BeforeConnect != nil {
+		if err := l.config.BeforeConnect(ctx, cfg); err != nil {
+			return err
+		}
+	}
+
+	var (
+		conn *pgx.Conn
+		err  error
+	)
+	if l.config.Tracer != nil {
+		var c *Conn
+		if c, err = Connect(ctx, cfg, l.config.Tracer); err == nil {
+			conn = c.Conn
+		}
+	} else {
+		conn, err = pgx.ConnectConfig(ctx, cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		conn.Close(closeCtx)
+	}()
+
+	l.mu.RLock()
+	channels := make([]string, 0, len(l.handlers))
+	for channel := range l.handlers {
+		channels = append(channels, channel)
+	}
+	l.mu.RUnlock()
+
+	for _, channel := range channels {
+		if err := l.exec(ctx, conn, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", channel, err)
+		}
+	}
+	slog.InfoContext(ctx, "Listening for notifications", "channels", strings.Join(channels, ","))
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		l.dispatch(ctx, n)
+	}
+}
+
+// exec runs sql on conn, commented when a Tracer is configured
+func (l *Listener) exec(ctx context.Context, conn *pgx.Conn, sql string) error {
+	var args []any
+	if l.config.Tracer != nil {
+		args = l.config.Tracer.withComment(nil)
+	}
+	_, err := conn.Exec(ctx, sql, args...)
+	return err
+}
+
+// dispatch calls the handlers of the notification's channel inside a
+// consumer span
+func (l *Listener) dispatch(ctx context.Context, n *pgconn.Notification) {
+	l.mu.RLock()
+	handlers := l.handlers[n.Channel]
+	l.mu.RUnlock()
+
+	ctx = extractPayload(ctx, n.Payload)
+	ctx, span := l.tracer.Start(ctx, n.Channel+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("postgresql"),
+			semconv.MessagingOperationKey.String("process"),
+			semconv.MessagingDestinationName(n.Channel),
+			semconv.MessagingMessageBodySize(len(n.Payload)),
+			attribute.Int64("db.postgresql.notify.pid", int64(n.PID)),
+		),
+	)
+	defer span.End()
+
+	var errs []error
+	for _, h := range handlers {
+		if err := h(ctx, n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.ErrorContext(ctx, "Notification handler failed", "channel", n.Channel, "error", err)
+	}
+}
+
+// TracePayload encodes fields as a JSON notification payload carrying the
+// traceparent and tracestate of ctx, for use with pg_notify
+func TracePayload(ctx context.Context, fields map[string]any) (string, error) {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+
+	payload := make(map[string]any, len(fields)+len(carrier))
+	for k, v := range fields {
+		payload[k] = v
+	}
+	for k, v := range carrier {
+		payload[k] = v
+	}
+	b, err := json.Marshal(payload)
+	return string(b), err
+}
+
+// extractPayload returns ctx with the remote span context of the payload's
+// traceparent field, or ctx unchanged when the payload has none
+func extractPayload(ctx context.Context, payload string) context.Context {
+	if !strings.HasPrefix(strings.TrimSpace(payload), "{") {
+		return ctx
+	}
+	var fields struct {
+		Traceparent string `json:"traceparent"`
+		Tracestate  string `json:"tracestate"`
+	}
+	if err := json.Unmarshal([]byte(payload), &fields); err != nil || fields.Traceparent == "" {
+		return ctx
+	}
+	return propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{
+		"traceparent": fields.Traceparent,
+		"tracestate":  fields.Tracestate,
+	})
+}
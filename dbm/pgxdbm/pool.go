@@ -0,0 +1,130 @@
+package pgxdbm
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Configure installs t as the query tracer of cfg.
+//
+// Every commented query has unique text, so the default statement cache
+// would prepare (and evict) a statement per query. The exec mode is switched
+// to describe-exec, which uses the unnamed prepared statement instead.
+func Configure(cfg *pgx.ConnConfig, t *QueryTracer) {
+	cfg.Tracer = t
+	cfg.DefaultQueryExecMode = pgx.QueryExecModeDescribeExec
+}
+
+// Pool is a pgxpool.Pool whose queries are traced and commented
+type Pool struct {
+	*pgxpool.Pool
+	tracer *QueryTracer
+}
+
+// NewPool creates a traced and commented pool from cfg
+func NewPool(ctx context.Context, cfg *pgxpool.Config, t *QueryTracer) (*Pool, error) {
+	Configure(cfg.ConnConfig, t)
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Pool{Pool: pool, tracer: t}, nil
+}
+
+// Exec executes sql with a comment
+func (p *Pool) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return p.Pool.Exec(ctx, sql, p.tracer.withComment(args)...)
+}
+
+// Query runs sql with a comment
+func (p *Pool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return p.Pool.Query(ctx, sql, p.tracer.withComment(args)...)
+}
+
+// QueryRow runs sql with a comment
+func (p *Pool) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return p.Pool.QueryRow(ctx, sql, p.tracer.withComment(args)...)
+}
+
+// Begin starts a traced and commented transaction
+func (p *Pool) Begin(ctx context.Context) (*Tx, error) {
+	return p.BeginTx(ctx, pgx.TxOptions{})
+}
+
+// BeginTx starts a traced and commented transaction with txOptions
+func (p *Pool) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (*Tx, error) {
+	tx, err := p.Pool.BeginTx(ctx, txOptions)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, tracer: p.tracer}, nil
+}
+
+// Conn is a pgx.Conn whose queries are traced and commented
+type Conn struct {
+	*pgx.Conn
+	tracer *QueryTracer
+}
+
+// Connect opens a traced and commented connection from cfg
+func Connect(ctx context.Context, cfg *pgx.ConnConfig, t *QueryTracer) (*Conn, error) {
+	Configure(cfg, t)
+	conn, err := pgx.ConnectConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{Conn: conn, tracer: t}, nil
+}
+
+// Exec executes sql with a comment
+func (c *Conn) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return c.Conn.Exec(ctx, sql, c.tracer.withComment(args)...)
+}
+
+// Query runs sql with a comment
+func (c *Conn) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return c.Conn.Query(ctx, sql, c.tracer.withComment(args)...)
+}
+
+// QueryRow runs sql with a comment
+func (c *Conn) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return c.Conn.QueryRow(ctx, sql, c.tracer.withComment(args)...)
+}
+
+// Begin starts a traced and commented transaction
+func (c *Conn) Begin(ctx context.Context) (*Tx, error) {
+	return c.BeginTx(ctx, pgx.TxOptions{})
+}
+
+// BeginTx starts a traced and commented transaction with txOptions
+func (c *Conn) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (*Tx, error) {
+	tx, err := c.Conn.BeginTx(ctx, txOptions)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, tracer: c.tracer}, nil
+}
+
+// Tx is a pgx.Tx whose queries are traced and commented
+type Tx struct {
+	pgx.Tx
+	tracer *QueryTracer
+}
+
+// Exec executes sql with a comment
+func (t *Tx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return t.Tx.Exec(ctx, sql, t.tracer.withComment(args)...)
+}
+
+// Query runs sql with a comment
+func (t *Tx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return t.Tx.Query(ctx, sql, t.tracer.withComment(args)...)
+}
+
+// QueryRow runs sql with a comment
+func (t *Tx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return t.Tx.QueryRow(ctx, sql, t.tracer.withComment(args)...)
+}
@@ -0,0 +1,116 @@
+// Package pgxdbm adds OpenTelemetry spans and Datadog SQL comments to
+// queries issued through jackc/pgx/v5.
+package pgxdbm
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"otel-go-dbm/dbm"
+)
+
+const instrumentationName = "otel-go-dbm/dbm/pgxdbm"
+
+// QueryTracer is a pgx.QueryTracer that creates a span per query.
+// Combined with the Pool, Conn and Tx wrappers it also comments each query
+// with the ID of that span.
+type QueryTracer struct {
+	tracer    trace.Tracer
+	commenter *dbm.Commenter
+	attrs     []attribute.KeyValue
+}
+
+var _ pgx.QueryTracer = (*QueryTracer)(nil)
+
+// NewQueryTracer creates a new QueryTracer.
+// attrs are added to every span, e.g. semconv.DBName.
+func NewQueryTracer(commenter *dbm.Commenter, attrs ...attribute.KeyValue) *QueryTracer {
+	return &QueryTracer{
+		tracer:    otel.GetTracerProvider().Tracer(instrumentationName),
+		commenter: commenter,
+		attrs:     attrs,
+	}
+}
+
+// TraceQueryStart starts the query span
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	attrs := make([]attribute.KeyValue, 0, len(t.attrs)+4)
+	attrs = append(attrs, semconv.DBSystemPostgreSQL, semconv.DBStatement(data.SQL))
+	if conn != nil {
+		cfg := conn.Config()
+		attrs = append(attrs, semconv.DBName(cfg.Database), semconv.ServerAddress(cfg.Host))
+	}
+	attrs = append(attrs, t.attrs...)
+
+	ctx, _ = t.tracer.Start(ctx, "pgx.query",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	return ctx
+}
+
+// TraceQueryEnd ends the query span, recording the error and the rows
+// returned by a SELECT or affected by other statements
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+		return
+	}
+	if data.CommandTag.Select() {
+		span.SetAttributes(dbm.AttrReturnedRows.Int64(data.CommandTag.RowsAffected()))
+	} else {
+		span.SetAttributes(dbm.AttrRowsAffected.Int64(data.CommandTag.RowsAffected()))
+	}
+}
+
+// commentRewriter is a pgx.QueryRewriter that comments the query.
+// pgx runs rewriters after TraceQueryStart, so the comment carries the query span.
+type commentRewriter struct {
+	commenter *dbm.Commenter
+	next      pgx.QueryRewriter
+}
+
+// RewriteQuery applies the caller's rewriter, if any, then comments the query
+func (r *commentRewriter) RewriteQuery(ctx context.Context, conn *pgx.Conn, sql string, args []any) (string, []any, error) {
+	if r.next != nil {
+		var err error
+		sql, args, err = r.next.RewriteQuery(ctx, conn, sql, args)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return r.commenter.Inject(ctx, sql), args, nil
+}
+
+// withComment returns args with a commenting rewriter placed among the
+// leading pgx option arguments. pgx honors only one rewriter, so a rewriter
+// passed by the caller (e.g. pgx.NamedArgs) is wrapped instead of replaced.
+func (t *QueryTracer) withComment(args []any) []any {
+	i := 0
+	for ; i < len(args); i++ {
+		switch arg := args[i].(type) {
+		case pgx.QueryRewriter:
+			out := append([]any(nil), args...)
+			out[i] = &commentRewriter{commenter: t.commenter, next: arg}
+			return out
+		case pgx.QueryExecMode, pgx.QueryResultFormats, pgx.QueryResultFormatsByOID:
+			continue
+		}
+		break
+	}
+
+	out := make([]any, 0, len(args)+1)
+	out = append(out, args[:i]...)
+	out = append(out, &commentRewriter{commenter: t.commenter})
+	return append(out, args[i:]...)
+}
@@ -0,0 +1,148 @@
+package dbm
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"strconv"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SQLSTATE codes that are safe to retry
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+	sqlStateAdminShutdown        = "57P01"
+)
+
+// SQL Server deadlock victim error number
+const mssqlDeadlockVictim = 1205
+
+// RetryConfig holds configuration for Retrier
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts, including the first
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry. It doubles on each
+	// retry up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Retryable reports whether err is transient. Defaults to IsTransient.
+	Retryable func(err error) bool
+}
+
+// Retrier retries database work that failed with a transient error,
+// backing off exponentially between attempts
+type Retrier struct {
+	config  RetryConfig
+	retries metric.Int64Counter
+}
+
+// NewRetrier creates a new Retrier
+func NewRetrier(config *RetryConfig) *Retrier {
+	var cfg RetryConfig
+	if config != nil {
+		cfg = *config
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = 50 * time.Millisecond
+	}
+	if cfg.MaxBackoff < cfg.InitialBackoff {
+		cfg.MaxBackoff = cfg.InitialBackoff
+	}
+	if cfg.Retryable == nil {
+		cfg.Retryable = IsTransient
+	}
+
+	retries, err := otel.GetMeterProvider().Meter(instrumentationName).Int64Counter("db.client.retries",
+		metric.WithDescription("Number of database operations retried after a transient error"),
+		metric.WithUnit("{retry}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return &Retrier{config: cfg, retries: retries}
+}
+
+// Do calls fn until it succeeds, returns a non-transient error or the
+// attempts are exhausted, and returns the last error.
+//
+// fn must be safe to repeat: a serialization failure requires re-running
+// the whole transaction, so wrap WithTx rather than a single statement.
+// Each retry is recorded as a "retry" event on the span in ctx.
+func (r *Retrier) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	backoff := r.config.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= r.config.MaxAttempts || !r.config.Retryable(err) {
+			return err
+		}
+
+		// Equal jitter keeps concurrent retries from hitting the database in lockstep
+		wait := backoff/2 + rand.N(backoff/2+1)
+		errType := errorType(err)
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.Int("db.retry.attempt", attempt),
+			attribute.String("error.type", errType),
+			attribute.String("exception.message", err.Error()),
+			attribute.Int64("db.retry.backoff_ms", wait.Milliseconds()),
+		))
+		if r.retries != nil {
+			r.retries.Add(ctx, 1, metric.WithAttributes(attribute.String("error.type", errType)))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		backoff = min(backoff*2, r.config.MaxBackoff)
+	}
+}
+
+// IsTransient reports whether err is a serialization failure, deadlock,
+// administrator shutdown (57P01) or dropped connection, which succeed
+// when retried
+func IsTransient(err error) bool {
+	switch errorType(err) {
+	case sqlStateSerializationFailure, sqlStateDeadlockDetected, sqlStateAdminShutdown,
+		strconv.Itoa(mssqlDeadlockVictim), "connection_reset":
+		return true
+	}
+	return false
+}
+
+// errorType classifies err for span events and metrics: the SQLSTATE or
+// SQL Server error number when the driver reports one, "connection_reset"
+// for dropped connections, and "other" otherwise
+func errorType(err error) string {
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		return state.SQLState()
+	}
+	var number interface{ SQLErrorNumber() int32 }
+	if errors.As(err, &number) {
+		return strconv.Itoa(int(number.SQLErrorNumber()))
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return "connection_reset"
+	}
+	return "other"
+}
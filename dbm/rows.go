@@ -0,0 +1,88 @@
+package dbm
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+)
+
+// hookRows calls onNext after each row is read, when set, and onClose after
+// the wrapped rows are closed, e.g. to release the statement deadline
+type hookRows struct {
+	driver.Rows
+	onNext  func(err error)
+	onClose func()
+}
+
+var (
+	_ driver.RowsNextResultSet              = (*hookRows)(nil)
+	_ driver.RowsColumnTypeDatabaseTypeName = (*hookRows)(nil)
+	_ driver.RowsColumnTypeLength           = (*hookRows)(nil)
+	_ driver.RowsColumnTypeNullable         = (*hookRows)(nil)
+	_ driver.RowsColumnTypePrecisionScale   = (*hookRows)(nil)
+	_ driver.RowsColumnTypeScanType         = (*hookRows)(nil)
+)
+
+// Next reads the next row and calls onNext
+func (r *hookRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if r.onNext != nil {
+		r.onNext(err)
+	}
+	return err
+}
+
+// Close closes the wrapped rows and calls onClose
+func (r *hookRows) Close() error {
+	defer r.onClose()
+	return r.Rows.Close()
+}
+
+func (r *hookRows) HasNextResultSet() bool {
+	if n, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return n.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *hookRows) NextResultSet() error {
+	if n, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return n.NextResultSet()
+	}
+	return errors.New("dbm: driver does not support multiple result sets")
+}
+
+func (r *hookRows) ColumnTypeDatabaseTypeName(index int) string {
+	if t, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return t.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *hookRows) ColumnTypeLength(index int) (int64, bool) {
+	if t, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return t.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *hookRows) ColumnTypeNullable(index int) (bool, bool) {
+	if t, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return t.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r *hookRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	if t, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return t.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+func (r *hookRows) ColumnTypeScanType(index int) reflect.Type {
+	if t, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return t.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(any)).Elem()
+}
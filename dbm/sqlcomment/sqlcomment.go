@@ -0,0 +1,163 @@
+// Package sqlcomment parses sqlcommenter-formatted SQL comments back into
+// their tags and validates the traceparent they carry.
+package sqlcomment
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrNoComment is returned when the query carries no comment to parse
+var ErrNoComment = errors.New("sqlcomment: query has no comment")
+
+// Traceparent is a parsed W3C traceparent value
+type Traceparent struct {
+	Version    byte
+	TraceID    trace.TraceID
+	SpanID     trace.SpanID
+	TraceFlags trace.TraceFlags
+}
+
+// Parse extracts the tags of the comment in query.
+// The leading comment is used when present (Datadog style), otherwise the
+// trailing comment (sqlcommenter style).
+func Parse(query string) (map[string]string, error) {
+	comment, ok := extract(query)
+	if !ok {
+		return nil, ErrNoComment
+	}
+	return ParseComment(comment)
+}
+
+// ParseComment parses the body of a comment, without the /* */ delimiters,
+// into its tags
+func ParseComment(comment string) (map[string]string, error) {
+	tags := make(map[string]string)
+	rest := strings.TrimSpace(comment)
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("sqlcomment: missing key in %q", rest)
+		}
+		key := unescape(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+
+		value, n, err := quoted(rest)
+		if err != nil {
+			return nil, fmt.Errorf("sqlcomment: value of %q: %w", key, err)
+		}
+		tags[key] = unescape(value)
+		rest = strings.TrimSpace(rest[n:])
+
+		if rest == "" {
+			break
+		}
+		if rest[0] != ',' {
+			return nil, fmt.Errorf("sqlcomment: expected ',' after %q", key)
+		}
+		rest = strings.TrimSpace(rest[1:])
+	}
+	return tags, nil
+}
+
+// ParseTraceparent parses and validates a W3C traceparent value
+func ParseTraceparent(s string) (Traceparent, error) {
+	var tp Traceparent
+
+	parts := strings.Split(s, "-")
+	if len(parts) != 4 {
+		return tp, fmt.Errorf("sqlcomment: traceparent %q must have 4 fields", s)
+	}
+	if len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return tp, fmt.Errorf("sqlcomment: traceparent %q has invalid field lengths", s)
+	}
+	if strings.ToLower(s) != s {
+		return tp, fmt.Errorf("sqlcomment: traceparent %q must be lowercase", s)
+	}
+
+	version, err := strconv.ParseUint(parts[0], 16, 8)
+	if err != nil {
+		return tp, fmt.Errorf("sqlcomment: invalid traceparent version: %w", err)
+	}
+	if version == 0xff {
+		return tp, fmt.Errorf("sqlcomment: traceparent version ff is forbidden")
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return tp, fmt.Errorf("sqlcomment: invalid traceparent flags: %w", err)
+	}
+
+	traceID, err := trace.TraceIDFromHex(parts[1])
+	if err != nil {
+		return tp, fmt.Errorf("sqlcomment: invalid trace ID: %w", err)
+	}
+	spanID, err := trace.SpanIDFromHex(parts[2])
+	if err != nil {
+		return tp, fmt.Errorf("sqlcomment: invalid span ID: %w", err)
+	}
+
+	tp.Version = byte(version)
+	tp.TraceID = traceID
+	tp.SpanID = spanID
+	tp.TraceFlags = trace.TraceFlags(flags)
+	return tp, nil
+}
+
+// ValidateTraceparent reports whether s is a valid W3C traceparent value
+func ValidateTraceparent(s string) error {
+	_, err := ParseTraceparent(s)
+	return err
+}
+
+// extract returns the body of the leading or trailing comment of query
+func extract(query string) (string, bool) {
+	q := strings.TrimSpace(query)
+	if strings.HasPrefix(q, "/*") {
+		if end := strings.Index(q, "*/"); end >= 0 {
+			return q[2:end], true
+		}
+		return "", false
+	}
+	if strings.HasSuffix(q, "*/") {
+		if start := strings.LastIndex(q, "/*"); start >= 0 {
+			return q[start+2 : len(q)-2], true
+		}
+	}
+	return "", false
+}
+
+// quoted reads a single-quoted value at the start of s, honoring \' escapes.
+// It returns the raw value and the number of bytes consumed.
+func quoted(s string) (string, int, error) {
+	if s == "" || s[0] != '\'' {
+		return "", 0, errors.New("value must be single-quoted")
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+				continue
+			}
+		case '\'':
+			return b.String(), i + 1, nil
+		}
+		b.WriteByte(s[i])
+	}
+	return "", 0, errors.New("unterminated value")
+}
+
+// unescape URL-decodes s, returning it unchanged if it is not valid encoding
+func unescape(s string) string {
+	if decoded, err := url.PathUnescape(s); err == nil {
+		return decoded
+	}
+	return s
+}
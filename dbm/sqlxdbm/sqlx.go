@@ -0,0 +1,94 @@
+// Package sqlxdbm opens jmoiron/sqlx databases whose queries are traced by
+// otelsql and commented by dbm, the same as the database/sql path.
+package sqlxdbm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+
+	"github.com/XSAM/otelsql"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"otel-go-dbm/dbm"
+)
+
+const instrumentationName = "otel-go-dbm/dbm/sqlxdbm"
+
+// DB is a sqlx.DB whose queries are traced and commented.
+//
+// Comments are injected at the driver level, after sqlx has rewritten named
+// parameters, so tag values are never mistaken for :name parameters.
+// Use the *Context methods so the request span reaches the driver.
+type DB struct {
+	*sqlx.DB
+	tracer trace.Tracer
+}
+
+// Open wraps c with comment injection and otelsql and returns it as a DB.
+// driverName selects the sqlx bind type, e.g. "postgres".
+func Open(c driver.Connector, driverName string, commenter *dbm.Commenter, opts ...otelsql.Option) *DB {
+	db := otelsql.OpenDB(dbm.NewConnector(c, commenter), opts...)
+	return &DB{
+		DB:     sqlx.NewDb(db, driverName),
+		tracer: otel.GetTracerProvider().Tracer(instrumentationName),
+	}
+}
+
+// NamedExecContext binds the named query and executes it inside a span
+// recording the original named statement and the rows affected
+func (db *DB) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	ctx, span := db.startNamed(ctx, "sqlx.named_exec", query)
+	defer span.End()
+
+	bound, args, err := db.BindNamed(query, arg)
+	if err != nil {
+		recordError(span, err)
+		return nil, err
+	}
+	result, err := db.ExecContext(ctx, bound, args...)
+	if err != nil {
+		recordError(span, err)
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err == nil {
+		span.SetAttributes(dbm.AttrRowsAffected.Int64(n))
+	}
+	return result, nil
+}
+
+// NamedQueryContext binds the named query and runs it inside a span
+// recording the original named statement
+func (db *DB) NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error) {
+	ctx, span := db.startNamed(ctx, "sqlx.named_query", query)
+	defer span.End()
+
+	bound, args, err := db.BindNamed(query, arg)
+	if err != nil {
+		recordError(span, err)
+		return nil, err
+	}
+	rows, err := db.QueryxContext(ctx, bound, args...)
+	if err != nil {
+		recordError(span, err)
+	}
+	return rows, err
+}
+
+// startNamed starts a span for a named query
+func (db *DB) startNamed(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	return db.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(semconv.DBStatement(query)),
+	)
+}
+
+// recordError marks span as failed with err
+func recordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,86 @@
+package dbm
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+)
+
+// commentedStmt applies the statement timeout, lifecycle events and slow
+// query EXPLAIN to a prepared statement, which database/sql uses when the
+// driver cannot run a query directly
+type commentedStmt struct {
+	driver.Stmt
+	conn *commentedConn
+
+	// query is the statement text without the comment
+	query string
+}
+
+var (
+	_ driver.StmtQueryContext  = (*commentedStmt)(nil)
+	_ driver.StmtExecContext   = (*commentedStmt)(nil)
+	_ driver.NamedValueChecker = (*commentedStmt)(nil)
+	_ driver.ColumnConverter   = (*commentedStmt)(nil)
+)
+
+// QueryContext runs the statement
+func (s *commentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.runQuery(ctx, s.query, args, func(ctx context.Context) (driver.Rows, error) {
+		if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+			return q.QueryContext(ctx, args)
+		}
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return nil, err
+		}
+		return s.Stmt.Query(values)
+	})
+}
+
+// ExecContext runs the statement
+func (s *commentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.runExec(ctx, s.query, args, func(ctx context.Context) (driver.Result, error) {
+		if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+			return e.ExecContext(ctx, args)
+		}
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return nil, err
+		}
+		return s.Stmt.Exec(values)
+	})
+}
+
+// CheckNamedValue delegates to the statement, then the connection, as
+// database/sql would for an unwrapped statement
+func (s *commentedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	if n, ok := s.conn.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// ColumnConverter delegates to the statement, defaulting to the standard converter
+func (s *commentedStmt) ColumnConverter(idx int) driver.ValueConverter {
+	if c, ok := s.Stmt.(driver.ColumnConverter); ok {
+		return c.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
+
+// namedValuesToValues converts positional arguments for drivers without
+// context support
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("dbm: driver does not support named parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
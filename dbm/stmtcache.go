@@ -0,0 +1,224 @@
+package dbm
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SQLSTATEs of a PostgreSQL prepared statement that must be prepared again
+const (
+	sqlStateFeatureNotSupported  = "0A000" // cached plan must not change result type
+	sqlStateInvalidStatementName = "26000" // prepared statement does not exist
+)
+
+// database/sql does not export the error of a closed statement
+const errStmtClosed = "sql: statement is closed"
+
+// StmtCacheConfig holds configuration for StmtCache
+type StmtCacheConfig struct {
+	// DB is the database the statements are prepared on
+	DB *sql.DB
+
+	// Size is the maximum number of cached statements. Defaults to 100.
+	Size int
+}
+
+// StmtCache reuses prepared statements across requests, evicting the least
+// recently used statement when full.
+//
+// Statements are keyed by the exact query text, since whitespace inside
+// quoted literals and line comments is significant. They are prepared with WithServiceComment, because a statement outlives the request
+// that prepared it: its comment carries the service tags but no traceparent.
+type StmtCache struct {
+	config StmtCacheConfig
+
+	mu    sync.Mutex
+	lru   *list.List
+	stmts map[string]*list.Element
+
+	hits      metric.Int64Counter
+	misses    metric.Int64Counter
+	evictions metric.Int64Counter
+}
+
+type cachedStmt struct {
+	key  string
+	stmt *sql.Stmt
+}
+
+// NewStmtCache creates a new StmtCache
+func NewStmtCache(config *StmtCacheConfig) *StmtCache {
+	cfg := *config
+	if cfg.Size < 1 {
+		cfg.Size = 100
+	}
+
+	meter := otel.GetMeterProvider().Meter(instrumentationName)
+	counter := func(name, description string) metric.Int64Counter {
+		c, err := meter.Int64Counter(name, metric.WithDescription(description), metric.WithUnit("{statement}"))
+		if err != nil {
+			otel.Handle(err)
+		}
+		return c
+	}
+
+	return &StmtCache{
+		config:    cfg,
+		lru:       list.New(),
+		stmts:     make(map[string]*list.Element),
+		hits:      counter("db.client.stmt_cache.hits", "Number of prepared statements reused from the cache"),
+		misses:    counter("db.client.stmt_cache.misses", "Number of statements prepared because they were not cached"),
+		evictions: counter("db.client.stmt_cache.evictions", "Number of prepared statements closed by the cache"),
+	}
+}
+
+// QueryContext runs query as a cached prepared statement
+func (c *StmtCache) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := c.do(ctx, query, func(ctx context.Context, stmt *sql.Stmt) error {
+		var err error
+		rows, err = stmt.QueryContext(ctx, args...)
+		return err
+	})
+	return rows, err
+}
+
+// ExecContext runs query as a cached prepared statement
+func (c *StmtCache) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	var result sql.Result
+	err := c.do(ctx, query, func(ctx context.Context, stmt *sql.Stmt) error {
+		var err error
+		result, err = stmt.ExecContext(ctx, args...)
+		return err
+	})
+	return result, err
+}
+
+// do calls fn with the cached statement for query. A statement closed by an
+// eviction in the meantime is prepared again once.
+func (c *StmtCache) do(ctx context.Context, query string, fn func(context.Context, *sql.Stmt) error) error {
+	// database/sql prepares the statement again on each new connection with
+	// the context of the query, so it must be limited to the service tags too
+	sctx := WithServiceComment(ctx)
+
+	stmt, err := c.stmt(ctx, query)
+	if err != nil {
+		return err
+	}
+	err = fn(sctx, stmt)
+	if err != nil && err.Error() == errStmtClosed {
+		if stmt, err = c.stmt(ctx, query); err != nil {
+			return err
+		}
+		err = fn(sctx, stmt)
+	}
+	if err != nil {
+		c.invalidate(ctx, query, err)
+	}
+	return err
+}
+
+// Close closes all cached statements
+func (c *StmtCache) Close() error {
+	c.mu.Lock()
+	stmts := make([]*sql.Stmt, 0, c.lru.Len())
+	for e := c.lru.Front(); e != nil; e = e.Next() {
+		stmts = append(stmts, e.Value.(*cachedStmt).stmt)
+	}
+	c.lru.Init()
+	c.stmts = make(map[string]*list.Element)
+	c.mu.Unlock()
+
+	var errs []error
+	for _, stmt := range stmts {
+		errs = append(errs, stmt.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// stmt returns the cached statement for query, preparing it on a miss.
+// The hit or miss is recorded on the span in ctx.
+func (c *StmtCache) stmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	key := query
+	span := trace.SpanFromContext(ctx)
+
+	c.mu.Lock()
+	if e, ok := c.stmts[key]; ok {
+		c.lru.MoveToFront(e)
+		c.mu.Unlock()
+		c.hits.Add(ctx, 1)
+		span.SetAttributes(attribute.Bool("db.stmt_cache.hit", true))
+		return e.Value.(*cachedStmt).stmt, nil
+	}
+	c.mu.Unlock()
+
+	c.misses.Add(ctx, 1)
+	span.SetAttributes(attribute.Bool("db.stmt_cache.hit", false))
+	stmt, err := c.config.DB.PrepareContext(WithServiceComment(ctx), query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	// Another request may have prepared the same query meanwhile
+	if e, ok := c.stmts[key]; ok {
+		c.lru.MoveToFront(e)
+		c.mu.Unlock()
+		stmt.Close()
+		return e.Value.(*cachedStmt).stmt, nil
+	}
+	c.stmts[key] = c.lru.PushFront(&cachedStmt{key: key, stmt: stmt})
+	var evicted *cachedStmt
+	if c.lru.Len() > c.config.Size {
+		evicted = c.lru.Remove(c.lru.Back()).(*cachedStmt)
+		delete(c.stmts, evicted.key)
+	}
+	c.mu.Unlock()
+
+	if evicted != nil {
+		c.evictions.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "capacity")))
+		c.closeStmt(evicted.stmt)
+	}
+	return stmt, nil
+}
+
+// invalidate drops the statement for query when err shows it can no longer
+// be used, e.g. after a schema change, and records it on the span in ctx
+func (c *StmtCache) invalidate(ctx context.Context, query string, err error) {
+	reason := errorType(err)
+	if reason != sqlStateFeatureNotSupported && reason != sqlStateInvalidStatementName {
+		return
+	}
+	key := query
+
+	c.mu.Lock()
+	e, ok := c.stmts[key]
+	if ok {
+		c.lru.Remove(e)
+		delete(c.stmts, key)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	c.evictions.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalidated")))
+	trace.SpanFromContext(ctx).AddEvent("stmt_cache.invalidate", trace.WithAttributes(
+		attribute.String("error.type", reason),
+	))
+	c.closeStmt(e.Value.(*cachedStmt).stmt)
+}
+
+// closeStmt closes stmt in the background, since Close waits for other
+// requests still reading rows from it
+func (c *StmtCache) closeStmt(stmt *sql.Stmt) {
+	go stmt.Close()
+}
@@ -0,0 +1,124 @@
+package dbm
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SQLSTATE of a PostgreSQL statement cancelled by statement_timeout
+const sqlStateQueryCanceled = "57014"
+
+// ConnectorOption configures NewConnector
+type ConnectorOption func(*connectorOptions)
+
+type connectorOptions struct {
+	statementTimeout   time.Duration
+	serverTimeout      bool
+	explainThreshold   time.Duration
+	explainMaxSize     int
+	operationMetrics   bool
+	slowQueryThreshold time.Duration
+	auditLogger        *slog.Logger
+}
+
+// WithStatementTimeout bounds every query and exec with a context deadline
+// of d. The deadline of a query lasts until its rows are closed.
+func WithStatementTimeout(d time.Duration) ConnectorOption {
+	return func(o *connectorOptions) {
+		o.statementTimeout = d
+	}
+}
+
+// WithServerStatementTimeout additionally sets the statement timeout on
+// each new connection, so the database itself cancels long statements:
+// statement_timeout on PostgreSQL and max_execution_time (SELECT only) on
+// MySQL. SQL Server has no per-session statement timeout and is skipped.
+func WithServerStatementTimeout() ConnectorOption {
+	return func(o *connectorOptions) {
+		o.serverTimeout = true
+	}
+}
+
+// setServerTimeout sets the session statement timeout on conn when enabled
+func (c *commentedConnector) setServerTimeout(ctx context.Context, conn driver.Conn) error {
+	ms := c.options.statementTimeout.Milliseconds()
+	if !c.options.serverTimeout || ms <= 0 {
+		return nil
+	}
+
+	var stmt string
+	switch c.commenter.config.Dialect {
+	case DialectPostgres:
+		stmt = fmt.Sprintf("SET statement_timeout = %d", ms)
+	case DialectMySQL:
+		stmt = fmt.Sprintf("SET SESSION max_execution_time = %d", ms)
+	default:
+		return nil
+	}
+
+	e, ok := conn.(driver.ExecerContext)
+	if !ok {
+		return nil
+	}
+	if _, err := e.ExecContext(ctx, stmt, nil); err != nil {
+		return fmt.Errorf("failed to set statement timeout: %w", err)
+	}
+	return nil
+}
+
+// queryWithTimeout runs query under the statement timeout. The returned rows
+// release the deadline when closed.
+func queryWithTimeout(ctx context.Context, timeout time.Duration, query func(context.Context) (driver.Rows, error)) (driver.Rows, error) {
+	if timeout <= 0 {
+		return query(ctx)
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, timeout)
+	rows, err := query(tctx)
+	if err != nil {
+		recordTimeout(ctx, tctx, timeout, err)
+		cancel()
+		return nil, err
+	}
+	return &hookRows{Rows: rows, onClose: cancel}, nil
+}
+
+// execWithTimeout runs exec under the statement timeout
+func execWithTimeout(ctx context.Context, timeout time.Duration, exec func(context.Context) (driver.Result, error)) (driver.Result, error) {
+	if timeout <= 0 {
+		return exec(ctx)
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	result, err := exec(tctx)
+	recordTimeout(ctx, tctx, timeout, err)
+	return result, err
+}
+
+// recordTimeout marks the span in ctx as failed by a statement timeout when
+// err was caused by the deadline of tctx or by the server-side timeout.
+// Cancellation of ctx itself, e.g. a client disconnect, is not a timeout.
+func recordTimeout(ctx, tctx context.Context, timeout time.Duration, err error) {
+	if err == nil || ctx.Err() != nil {
+		return
+	}
+	if !errors.Is(tctx.Err(), context.DeadlineExceeded) && errorType(err) != sqlStateQueryCanceled {
+		return
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		attribute.Bool("db.statement_timeout.exceeded", true),
+		attribute.Int64("db.statement_timeout.ms", timeout.Milliseconds()),
+	)
+	span.SetStatus(codes.Error, "statement timeout exceeded")
+}
@@ -0,0 +1,70 @@
+package dbm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "otel-go-dbm/dbm"
+
+// WithTx runs fn inside a transaction traced by a "db.transaction" span.
+//
+// The transaction is committed when fn returns nil and rolled back when fn
+// returns an error or panics; a panic is re-raised after the rollback.
+// Commit and rollback are recorded as span events. fn receives a context
+// carrying the span, and statements issued on tx with it become children of
+// the span and are commented when db was opened with NewConnector.
+func WithTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context, tx *sql.Tx) error) (err error) {
+	ctx, span := otel.GetTracerProvider().Tracer(instrumentationName).Start(ctx, "db.transaction",
+		trace.WithSpanKind(trace.SpanKindInternal),
+	)
+	defer span.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		recordError(span, err)
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			rollback(span, tx, fmt.Sprint(p))
+			recordError(span, fmt.Errorf("panic in transaction: %v", p))
+			panic(p)
+		}
+	}()
+
+	if err = fn(ctx, tx); err != nil {
+		rollback(span, tx, err.Error())
+		recordError(span, err)
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		recordError(span, err)
+		return err
+	}
+	span.AddEvent("commit")
+	return nil
+}
+
+// rollback rolls back tx and records the rollback and its reason on span
+func rollback(span trace.Span, tx *sql.Tx, reason string) {
+	attrs := []attribute.KeyValue{attribute.String("reason", reason)}
+	if err := tx.Rollback(); err != nil {
+		attrs = append(attrs, attribute.String("error", err.Error()))
+	}
+	span.AddEvent("rollback", trace.WithAttributes(attrs...))
+}
+
+// recordError marks span as failed with err
+func recordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
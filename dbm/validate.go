@@ -0,0 +1,82 @@
+package dbm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationMode controls how the commenter handles tags the sqlcommenter spec forbids
+type ValidationMode int
+
+const (
+	// ValidationOff emits tags as-is
+	ValidationOff ValidationMode = iota
+	// ValidationDrop drops invalid tags and logs a warning
+	ValidationDrop
+	// ValidationReject leaves the query uncommented when any tag is invalid
+	ValidationReject
+)
+
+// ParseValidationMode parses "off", "drop" or "reject" into a ValidationMode
+func ParseValidationMode(s string) (ValidationMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "off":
+		return ValidationOff, nil
+	case "drop":
+		return ValidationDrop, nil
+	case "reject":
+		return ValidationReject, nil
+	}
+	return ValidationOff, fmt.Errorf("unknown validation mode %q", s)
+}
+
+// String returns the name of the mode
+func (m ValidationMode) String() string {
+	switch m {
+	case ValidationDrop:
+		return "drop"
+	case ValidationReject:
+		return "reject"
+	}
+	return "off"
+}
+
+// validateTag reports why an escaped tag would produce a malformed comment,
+// or returns nil if the tag is valid
+func validateTag(key, value string) error {
+	if key == "" {
+		return fmt.Errorf("empty key")
+	}
+	for _, r := range key {
+		if !isKeyRune(r) {
+			return fmt.Errorf("key %q contains forbidden character %q", key, r)
+		}
+	}
+
+	for i, r := range value {
+		if r < 0x20 || r == 0x7f {
+			return fmt.Errorf("value of %q contains control character %q", key, r)
+		}
+		// Quotes must be escaped so the value stays inside its quotes
+		if r == '\'' && (i == 0 || value[i-1] != '\\') {
+			return fmt.Errorf("value of %q contains an unescaped quote", key)
+		}
+	}
+	// A trailing backslash would escape the closing quote
+	if strings.HasSuffix(value, "\\") && !strings.HasSuffix(value, "\\\\") {
+		return fmt.Errorf("value of %q ends with an escape character", key)
+	}
+	// Comment delimiters would terminate or nest the comment
+	if strings.Contains(value, "*/") || strings.Contains(value, "/*") {
+		return fmt.Errorf("value of %q contains a comment delimiter", key)
+	}
+	return nil
+}
+
+// isKeyRune reports whether r may appear in a tag key
+func isKeyRune(r rune) bool {
+	return r >= 'a' && r <= 'z' ||
+		r >= 'A' && r <= 'Z' ||
+		r >= '0' && r <= '9' ||
+		r == '_' || r == '-' || r == '.'
+}
@@ -0,0 +1,144 @@
+// Package ddexport sends spans to the native trace intake of the Datadog
+// Agent (/v0.4/traces), for environments where the Agent's OTLP intake is
+// disabled.
+package ddexport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// DefaultURL is the trace agent of a local Datadog Agent
+const DefaultURL = "http://localhost:8126"
+
+const tracesPath = "/v0.4/traces"
+
+// ExporterConfig holds configuration for Exporter
+type ExporterConfig struct {
+	// URL is the trace agent, http://host:port or
+	// unix:///var/run/datadog/apm.socket. Defaults to DefaultURL.
+	URL string
+
+	// Timeout bounds each request. Defaults to 10s.
+	Timeout time.Duration
+}
+
+// Exporter is a sdktrace.SpanExporter that sends spans to the Datadog
+// Agent in the v0.4 msgpack format. Use it with a batch span processor.
+type Exporter struct {
+	endpoint string
+	client   *http.Client
+	stopped  atomic.Bool
+}
+
+var _ sdktrace.SpanExporter = (*Exporter)(nil)
+
+// NewExporter creates a new Exporter
+func NewExporter(config *ExporterConfig) (*Exporter, error) {
+	var cfg ExporterConfig
+	if config != nil {
+		cfg = *config
+	}
+	if cfg.URL == "" {
+		cfg.URL = DefaultURL
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trace agent URL %q: %w", cfg.URL, err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	switch u.Scheme {
+	case "http", "https":
+		u.Path = tracesPath
+	case "unix":
+		socket := u.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+		u = &url.URL{Scheme: "http", Host: "localhost", Path: tracesPath}
+	default:
+		return nil, fmt.Errorf("unsupported trace agent URL scheme: %s (http, https or unix)", u.Scheme)
+	}
+
+	return &Exporter{
+		endpoint: u.String(),
+		client:   &http.Client{Transport: transport, Timeout: cfg.Timeout},
+	}, nil
+}
+
+// ExportSpans sends spans grouped by trace
+func (e *Exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if e.stopped.Load() || len(spans) == 0 {
+		return nil
+	}
+
+	var order []uint64
+	traces := make(map[uint64][]*span)
+	for _, s := range spans {
+		d := convert(s)
+		if _, ok := traces[d.traceID]; !ok {
+			order = append(order, d.traceID)
+		}
+		traces[d.traceID] = append(traces[d.traceID], d)
+	}
+
+	enc := &encoder{}
+	enc.arrayHeader(len(order))
+	for _, id := range order {
+		enc.arrayHeader(len(traces[id]))
+		for _, d := range traces[id] {
+			d.encode(enc)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(enc.buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/msgpack")
+	req.Header.Set("X-Datadog-Trace-Count", strconv.Itoa(len(order)))
+	req.Header.Set("Datadog-Meta-Lang", "go")
+	req.Header.Set("Datadog-Meta-Lang-Version", runtime.Version())
+	req.Header.Set("Datadog-Meta-Tracer-Version", "otel-go-dbm")
+	// Lets the Agent tag the traces with the metadata of the sending container
+	if res := spans[0].Resource(); res != nil {
+		if id, ok := res.Set().Value(semconv.ContainerIDKey); ok {
+			req.Header.Set("Datadog-Container-ID", id.AsString())
+		}
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send traces to the Datadog Agent: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("failed to send traces to the Datadog Agent: %s", resp.Status)
+	}
+	return nil
+}
+
+// Shutdown stops sending spans
+func (e *Exporter) Shutdown(context.Context) error {
+	e.stopped.Store(true)
+	e.client.CloseIdleConnections()
+	return nil
+}
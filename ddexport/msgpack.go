@@ -0,0 +1,75 @@
+package ddexport
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// encoder appends the MessagePack encoding of the few types the trace
+// intake needs
+type encoder struct {
+	buf []byte
+}
+
+// arrayHeader starts an array of n elements
+func (e *encoder) arrayHeader(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xdc)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdd)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+// mapHeader starts a map of n key/value pairs
+func (e *encoder) mapHeader(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xde)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdf)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+// string appends s as a str
+func (e *encoder) string(s string) {
+	switch n := len(s); {
+	case n < 32:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xda)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdb)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+// uint64 appends v as a uint 64
+func (e *encoder) uint64(v uint64) {
+	e.buf = append(e.buf, 0xcf)
+	e.buf = binary.BigEndian.AppendUint64(e.buf, v)
+}
+
+// int64 appends v as an int 64
+func (e *encoder) int64(v int64) {
+	e.buf = append(e.buf, 0xd3)
+	e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v))
+}
+
+// float64 appends v as a float 64
+func (e *encoder) float64(v float64) {
+	e.buf = append(e.buf, 0xcb)
+	e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v))
+}
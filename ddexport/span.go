@@ -0,0 +1,187 @@
+package ddexport
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"otel-go-dbm/spanproc"
+)
+
+// span is a span of the v0.4 trace intake
+type span struct {
+	service  string
+	name     string
+	resource string
+	spanType string
+	traceID  uint64
+	spanID   uint64
+	parentID uint64
+	start    int64
+	duration int64
+	error    int32
+	meta     map[string]string
+	metrics  map[string]float64
+}
+
+// convert maps an OpenTelemetry span to a Datadog span the way the Agent's
+// OTLP intake does: operation.name, resource.name and span.type (see
+// spanproc.DatadogNamingProcessor) become the span fields, string
+// attributes of the span and its resource become meta and numeric ones
+// metrics.
+func convert(s sdktrace.ReadOnlySpan) *span {
+	sc := s.SpanContext()
+	tid := sc.TraceID()
+	sid := sc.SpanID()
+	d := &span{
+		traceID:  binary.BigEndian.Uint64(tid[8:]),
+		spanID:   binary.BigEndian.Uint64(sid[:]),
+		start:    s.StartTime().UnixNano(),
+		duration: s.EndTime().Sub(s.StartTime()).Nanoseconds(),
+		meta:     make(map[string]string),
+		metrics:  make(map[string]float64),
+	}
+	if parent := s.Parent(); parent.IsValid() {
+		psid := parent.SpanID()
+		d.parentID = binary.BigEndian.Uint64(psid[:])
+	}
+	// The upper 64 bits of 128-bit trace IDs are carried as a tag
+	if upper := tid[:8]; binary.BigEndian.Uint64(upper) != 0 {
+		d.meta["_dd.p.tid"] = hex.EncodeToString(upper)
+	}
+	if !s.Parent().IsValid() || s.Parent().IsRemote() {
+		// Keep the traces sent by the application, which already sampled
+		// them, with the priority of the dd tracestate when it keeps them
+		priority, ok := spanproc.DatadogSamplingPriority(sc)
+		if !ok || priority < spanproc.PriorityAutoKeep {
+			priority = spanproc.PriorityAutoKeep
+		}
+		d.metrics["_sampling_priority_v1"] = float64(priority)
+	}
+
+	var resourceAttrs []attribute.KeyValue
+	if res := s.Resource(); res != nil {
+		resourceAttrs = res.Attributes()
+	}
+	for _, attrs := range [][]attribute.KeyValue{resourceAttrs, s.Attributes()} {
+		for _, attr := range attrs {
+			d.setAttribute(attr)
+		}
+	}
+	if env, ok := d.meta[string(semconv.DeploymentEnvironmentKey)]; ok {
+		d.meta["env"] = env
+	}
+	if version, ok := d.meta[string(semconv.ServiceVersionKey)]; ok {
+		d.meta["version"] = version
+	}
+
+	kind := strings.ToLower(s.SpanKind().String())
+	d.meta["span.kind"] = kind
+	scope := s.InstrumentationScope().Name
+	d.meta["otel.library.name"] = scope
+
+	if d.service == "" {
+		d.service = "unknown_service:go"
+	}
+	if d.name == "" {
+		d.name = fmt.Sprintf("%s.%s", scope, kind)
+	}
+	if d.resource == "" {
+		d.resource = s.Name()
+	}
+	if d.spanType == "" && s.SpanKind() == trace.SpanKindServer {
+		d.spanType = "web"
+	}
+
+	if s.Status().Code == codes.Error {
+		d.error = 1
+		d.meta["error.msg"] = s.Status().Description
+		for _, event := range s.Events() {
+			if event.Name != semconv.ExceptionEventName {
+				continue
+			}
+			for _, attr := range event.Attributes {
+				switch attr.Key {
+				case semconv.ExceptionTypeKey:
+					d.meta["error.type"] = attr.Value.Emit()
+				case semconv.ExceptionMessageKey:
+					d.meta["error.msg"] = attr.Value.Emit()
+				case semconv.ExceptionStacktraceKey:
+					d.meta["error.stack"] = attr.Value.Emit()
+				}
+			}
+		}
+	}
+	return d
+}
+
+// setAttribute sets attr as a span field, a metric or a tag
+func (d *span) setAttribute(attr attribute.KeyValue) {
+	switch attr.Key {
+	case spanproc.KeyOperationName:
+		d.name = attr.Value.Emit()
+		return
+	case spanproc.KeyResourceName:
+		d.resource = attr.Value.Emit()
+		return
+	case spanproc.KeySpanType:
+		d.spanType = attr.Value.Emit()
+		return
+	case semconv.ServiceNameKey:
+		d.service = attr.Value.Emit()
+		return
+	}
+
+	switch attr.Value.Type() {
+	case attribute.INT64:
+		d.metrics[string(attr.Key)] = float64(attr.Value.AsInt64())
+	case attribute.FLOAT64:
+		d.metrics[string(attr.Key)] = attr.Value.AsFloat64()
+	default:
+		d.meta[string(attr.Key)] = attr.Value.Emit()
+	}
+}
+
+// encode appends the span as a msgpack map
+func (d *span) encode(e *encoder) {
+	e.mapHeader(12)
+	e.string("service")
+	e.string(d.service)
+	e.string("name")
+	e.string(d.name)
+	e.string("resource")
+	e.string(d.resource)
+	e.string("type")
+	e.string(d.spanType)
+	e.string("trace_id")
+	e.uint64(d.traceID)
+	e.string("span_id")
+	e.uint64(d.spanID)
+	e.string("parent_id")
+	e.uint64(d.parentID)
+	e.string("start")
+	e.int64(d.start)
+	e.string("duration")
+	e.int64(d.duration)
+	e.string("error")
+	e.int64(int64(d.error))
+	e.string("meta")
+	e.mapHeader(len(d.meta))
+	for k, v := range d.meta {
+		e.string(k)
+		e.string(v)
+	}
+	e.string("metrics")
+	e.mapHeader(len(d.metrics))
+	for k, v := range d.metrics {
+		e.string(k)
+		e.float64(v)
+	}
+}
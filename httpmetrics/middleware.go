@@ -0,0 +1,136 @@
+// Package httpmetrics records HTTP server metrics per route pattern and
+// status code. otelhttp only labels its metrics with the method, scheme and
+// host, so per-endpoint latency and error rates need the route.
+package httpmetrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+const instrumentationName = "otel-go-dbm/httpmetrics"
+
+// DurationBuckets are the bucket boundaries of http.server.request.duration
+// in seconds, as recommended by the semantic conventions
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// SizeBuckets are the bucket boundaries of http.server.response.body.size
+// in bytes
+var SizeBuckets = []float64{0, 100, 1_000, 10_000, 100_000, 1_000_000, 10_000_000}
+
+type instruments struct {
+	requests     metric.Int64Counter
+	duration     metric.Float64Histogram
+	active       metric.Int64UpDownCounter
+	responseSize metric.Int64Histogram
+}
+
+// newInstruments creates the instruments once, on the global MeterProvider
+var newInstruments = sync.OnceValue(func() *instruments {
+	meter := otel.GetMeterProvider().Meter(instrumentationName)
+	var (
+		i   instruments
+		err error
+	)
+	if i.requests, err = meter.Int64Counter("http.server.requests",
+		metric.WithDescription("Number of HTTP requests handled"),
+		metric.WithUnit("{request}"),
+	); err != nil {
+		otel.Handle(err)
+	}
+	if i.duration, err = meter.Float64Histogram("http.server.request.duration",
+		metric.WithDescription("Duration of HTTP server requests"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(DurationBuckets...),
+	); err != nil {
+		otel.Handle(err)
+	}
+	if i.active, err = meter.Int64UpDownCounter("http.server.active_requests",
+		metric.WithDescription("Number of HTTP requests in flight"),
+		metric.WithUnit("{request}"),
+	); err != nil {
+		otel.Handle(err)
+	}
+	if i.responseSize, err = meter.Int64Histogram("http.server.response.body.size",
+		metric.WithDescription("Size of HTTP server response bodies"),
+		metric.WithUnit("By"),
+		metric.WithExplicitBucketBoundaries(SizeBuckets...),
+	); err != nil {
+		otel.Handle(err)
+	}
+	return &i
+})
+
+// Middleware records the request count, duration, in-flight requests and
+// response size of next, labeled with route (the registered pattern, not
+// the request path), the method and the status code
+func Middleware(route string, next http.Handler) http.Handler {
+	inst := newInstruments()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		start := time.Now()
+		routeAttrs := []attribute.KeyValue{
+			semconv.HTTPRoute(route),
+			semconv.HTTPRequestMethodKey.String(method(r.Method)),
+		}
+		inst.active.Add(ctx, 1, metric.WithAttributes(routeAttrs...))
+
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			inst.active.Add(ctx, -1, metric.WithAttributes(routeAttrs...))
+
+			attrs := metric.WithAttributes(append(routeAttrs, semconv.HTTPResponseStatusCode(rw.status))...)
+			inst.requests.Add(ctx, 1, attrs)
+			inst.duration.Record(ctx, time.Since(start).Seconds(), attrs)
+			inst.responseSize.Record(ctx, rw.written, attrs)
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// method returns m, or _OTHER for unknown methods so arbitrary methods do
+// not add label values
+func method(m string) string {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return m
+	}
+	return "_OTHER"
+}
+
+// responseWriter records the status code and the number of bytes written
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	written     int64
+	wroteHeader bool
+}
+
+// WriteHeader records the status code
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes written
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,52 @@
+package log
+
+import (
+	"log/slog"
+
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	otellog "go.opentelemetry.io/otel/log"
+)
+
+// DefaultBridgeName is the instrumentation scope of forwarded records
+const DefaultBridgeName = "otel-go-dbm/log"
+
+// BridgeHandlerConfig holds configuration for the handler of
+// NewBridgeHandler
+type BridgeHandlerConfig struct {
+	// LoggerProvider receives the records. Defaults to the global
+	// LoggerProvider.
+	LoggerProvider otellog.LoggerProvider
+
+	// Name is the instrumentation scope of the records. Defaults to
+	// DefaultBridgeName.
+	Name string
+
+	// Source adds code.filepath, code.lineno and code.function to the
+	// forwarded records
+	Source bool
+}
+
+// NewBridgeHandler creates a slog.Handler that forwards records to the
+// OpenTelemetry Logs SDK, which exports them with the trace and span IDs of
+// the context and the resource of the LoggerProvider. Add it as a sink of a
+// MultiHandler next to the console handler so logs take the same pipeline
+// as traces and metrics.
+//
+// The forwarded records carry the IDs natively, so the handler does not
+// need a TraceHandler.
+func NewBridgeHandler(config *BridgeHandlerConfig) slog.Handler {
+	cfg := BridgeHandlerConfig{Name: DefaultBridgeName}
+	if config != nil {
+		cfg.LoggerProvider = config.LoggerProvider
+		if config.Name != "" {
+			cfg.Name = config.Name
+		}
+		cfg.Source = config.Source
+	}
+
+	opts := []otelslog.Option{otelslog.WithSource(cfg.Source)}
+	if cfg.LoggerProvider != nil {
+		opts = append(opts, otelslog.WithLoggerProvider(cfg.LoggerProvider))
+	}
+	return otelslog.NewHandler(cfg.Name, opts...)
+}
@@ -0,0 +1,237 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultBufferMaxRecords is the number of records buffered per request by
+// default
+const DefaultBufferMaxRecords = 256
+
+type bufferKey struct{}
+
+// Buffer holds the records of one request until it is flushed or
+// discarded. Once either happens, records are written directly.
+type Buffer struct {
+	mu      sync.Mutex
+	records []bufferedRecord
+	max     int
+	done    bool
+}
+
+// bufferedRecord is a record with the handler and context to replay it
+type bufferedRecord struct {
+	ctx     context.Context
+	handler slog.Handler
+	record  slog.Record
+}
+
+// WithBuffer returns a copy of ctx whose records below the level of
+// BufferHandler are held in the returned Buffer, keeping the last
+// maxRecords of them (DefaultBufferMaxRecords when maxRecords <= 0)
+func WithBuffer(ctx context.Context, maxRecords int) (context.Context, *Buffer) {
+	if maxRecords <= 0 {
+		maxRecords = DefaultBufferMaxRecords
+	}
+	b := &Buffer{max: maxRecords}
+	return context.WithValue(ctx, bufferKey{}, b), b
+}
+
+// add holds r, dropping the oldest record when the buffer is full. It
+// returns false once the buffer is flushed or discarded.
+func (b *Buffer) add(ctx context.Context, h slog.Handler, r slog.Record) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done {
+		return false
+	}
+	if len(b.records) == b.max {
+		b.records = append(b.records[:0], b.records[1:]...)
+	}
+	b.records = append(b.records, bufferedRecord{ctx: ctx, handler: h, record: r.Clone()})
+	return true
+}
+
+// Flush writes the held records in order
+func (b *Buffer) Flush() error {
+	b.mu.Lock()
+	records := b.records
+	b.records, b.done = nil, true
+	b.mu.Unlock()
+
+	var errs []error
+	for _, br := range records {
+		if err := br.handler.Handle(br.ctx, br.record); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Discard drops the held records and returns how many were dropped
+func (b *Buffer) Discard() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(b.records)
+	b.records, b.done = nil, true
+	return n
+}
+
+// BufferHandlerConfig holds configuration for BufferHandler
+type BufferHandlerConfig struct {
+	// Level is the level from which records are written immediately;
+	// records below it are buffered. Defaults to slog.LevelWarn.
+	Level slog.Leveler
+
+	// FlushLevel is the level from which a record flushes the buffer of its
+	// request before it is written, so the error comes with the records
+	// leading to it. Defaults to slog.LevelError.
+	FlushLevel slog.Leveler
+}
+
+// BufferHandler is a slog.Handler that holds the debug and info records of
+// a request in the Buffer of its context (see WithBuffer and
+// BufferMiddleware) instead of writing them, a tail sampling of logs: the
+// records of failed or slow requests are written with their full context,
+// and those of the other requests are dropped. Records without a Buffer in
+// their context are written directly.
+type BufferHandler struct {
+	slog.Handler
+	level      slog.Leveler
+	flushLevel slog.Leveler
+}
+
+// NewBufferHandler creates a new BufferHandler
+func NewBufferHandler(h slog.Handler, config *BufferHandlerConfig) *BufferHandler {
+	cfg := BufferHandlerConfig{
+		Level:      slog.LevelWarn,
+		FlushLevel: slog.LevelError,
+	}
+	if config != nil {
+		if config.Level != nil {
+			cfg.Level = config.Level
+		}
+		if config.FlushLevel != nil {
+			cfg.FlushLevel = config.FlushLevel
+		}
+	}
+	return &BufferHandler{
+		Handler:    h,
+		level:      cfg.Level,
+		flushLevel: cfg.FlushLevel,
+	}
+}
+
+// Handle buffers the record if its context has a Buffer and its level is
+// below the buffered level, and otherwise writes it, flushing the buffer
+// first at the flush level
+func (h *BufferHandler) Handle(ctx context.Context, r slog.Record) error {
+	b, ok := ctx.Value(bufferKey{}).(*Buffer)
+	if !ok {
+		return h.Handler.Handle(ctx, r)
+	}
+	if r.Level < h.level.Level() {
+		if b.add(ctx, h.Handler, r) {
+			return nil
+		}
+		return h.Handler.Handle(ctx, r)
+	}
+
+	var errs []error
+	if r.Level >= h.flushLevel.Level() {
+		errs = append(errs, b.Flush())
+	}
+	errs = append(errs, h.Handler.Handle(ctx, r))
+	return errors.Join(errs...)
+}
+
+// WithAttrs returns a new BufferHandler with attributes added to the
+// underlying handler
+func (h *BufferHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &BufferHandler{
+		Handler:    h.Handler.WithAttrs(attrs),
+		level:      h.level,
+		flushLevel: h.flushLevel,
+	}
+}
+
+// WithGroup returns a new BufferHandler with a group added to the
+// underlying handler
+func (h *BufferHandler) WithGroup(name string) slog.Handler {
+	return &BufferHandler{
+		Handler:    h.Handler.WithGroup(name),
+		level:      h.level,
+		flushLevel: h.flushLevel,
+	}
+}
+
+// BufferMiddlewareConfig holds configuration for BufferMiddleware
+type BufferMiddlewareConfig struct {
+	// MaxRecords is the number of records buffered per request. Defaults to
+	// DefaultBufferMaxRecords.
+	MaxRecords int
+
+	// Latency flushes the buffer of requests taking at least this long.
+	// Optional.
+	Latency time.Duration
+}
+
+// BufferMiddleware gives each request handled by next a Buffer for
+// BufferHandler. The buffer is flushed when the response is a 5xx or the
+// request took at least Latency, and discarded otherwise.
+func BufferMiddleware(next http.Handler, config *BufferMiddlewareConfig) http.Handler {
+	var cfg BufferMiddlewareConfig
+	if config != nil {
+		cfg = *config
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx, b := WithBuffer(r.Context(), cfg.MaxRecords)
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			slow := cfg.Latency > 0 && time.Since(start) >= cfg.Latency
+			if p := recover(); p != nil {
+				b.Flush()
+				panic(p)
+			}
+			if sw.status >= http.StatusInternalServerError || slow {
+				b.Flush()
+				return
+			}
+			b.Discard()
+		}()
+		next.ServeHTTP(sw, r.WithContext(ctx))
+	})
+}
+
+// statusWriter records the status code of the response
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader records the status code
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write marks the header as written
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
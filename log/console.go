@@ -0,0 +1,220 @@
+package log
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// DefaultConsoleTimeFormat is the time format of ConsoleHandler
+const DefaultConsoleTimeFormat = "15:04:05.000"
+
+// ANSI escape sequences of ConsoleHandler
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// ConsoleHandlerConfig holds configuration for ConsoleHandler
+type ConsoleHandlerConfig struct {
+	// Level is the minimum level of the records written. Defaults to info.
+	Level slog.Leveler
+
+	// AddSource writes the file name and line of the logging call
+	AddSource bool
+
+	// NoColor writes plain text, e.g. when the output is not a terminal or
+	// NO_COLOR is set
+	NoColor bool
+
+	// TimeFormat defaults to DefaultConsoleTimeFormat
+	TimeFormat string
+}
+
+// ConsoleHandler is a slog.Handler writing one colorized, human-friendly
+// line per record for local development:
+//
+//	12:34:56.789 INF main.go:42 Server starting port=8080 trace_id=4bf9...
+//
+// Groups are written as dotted keys, and the stack trace added with
+// TraceHandlerConfig.StackTraces is written on the following lines.
+type ConsoleHandler struct {
+	config ConsoleHandlerConfig
+	mu     *sync.Mutex
+	w      io.Writer
+	attrs  string // attributes added with WithAttrs, formatted
+	prefix string // groups added with WithGroup, as a key prefix
+}
+
+// NewConsoleHandler creates a new ConsoleHandler writing to w
+func NewConsoleHandler(w io.Writer, config *ConsoleHandlerConfig) *ConsoleHandler {
+	cfg := ConsoleHandlerConfig{
+		Level:      slog.LevelInfo,
+		TimeFormat: DefaultConsoleTimeFormat,
+	}
+	if config != nil {
+		if config.Level != nil {
+			cfg.Level = config.Level
+		}
+		cfg.AddSource = config.AddSource
+		cfg.NoColor = config.NoColor
+		if config.TimeFormat != "" {
+			cfg.TimeFormat = config.TimeFormat
+		}
+	}
+	return &ConsoleHandler{config: cfg, mu: &sync.Mutex{}, w: w}
+}
+
+// Enabled reports whether level is at least the configured level
+func (h *ConsoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.config.Level.Level()
+}
+
+// Handle writes the record as one line
+func (h *ConsoleHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	if !r.Time.IsZero() {
+		h.colorize(&b, ansiDim, r.Time.Format(h.config.TimeFormat))
+		b.WriteByte(' ')
+	}
+	tag, color := consoleLevel(r.Level)
+	h.colorize(&b, color, tag)
+	b.WriteByte(' ')
+	if h.config.AddSource && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		h.colorize(&b, ansiDim, filepath.Base(frame.File)+":"+strconv.Itoa(frame.Line))
+		b.WriteByte(' ')
+	}
+	h.colorize(&b, ansiBold, r.Message)
+	b.WriteString(h.attrs)
+
+	var stack string
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == StackKey {
+			stack = a.Value.String()
+			return true
+		}
+		h.appendAttr(&b, h.prefix, a)
+		return true
+	})
+	b.WriteByte('\n')
+	if stack != "" {
+		for _, line := range strings.Split(strings.TrimRight(stack, "\n"), "\n") {
+			b.WriteString("    ")
+			h.colorize(&b, ansiDim, line)
+			b.WriteByte('\n')
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+// WithAttrs returns a new ConsoleHandler writing attrs on every line
+func (h *ConsoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	b.WriteString(h.attrs)
+	for _, a := range attrs {
+		h.appendAttr(&b, h.prefix, a)
+	}
+	h2 := *h
+	h2.attrs = b.String()
+	return &h2
+}
+
+// WithGroup returns a new ConsoleHandler prefixing the keys of the
+// attributes added after it with name
+func (h *ConsoleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// appendAttr writes a as key=value, groups as dotted keys
+func (h *ConsoleHandler) appendAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			h.appendAttr(b, prefix, ga)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+
+	b.WriteByte(' ')
+	h.colorize(b, ansiCyan, prefix+a.Key+"=")
+	value := consoleValue(v)
+	if _, ok := v.Any().(error); ok {
+		h.colorize(b, ansiRed, value)
+		return
+	}
+	b.WriteString(value)
+}
+
+// colorize writes s in color unless colors are disabled
+func (h *ConsoleHandler) colorize(b *strings.Builder, color, s string) {
+	if h.config.NoColor {
+		b.WriteString(s)
+		return
+	}
+	b.WriteString(color)
+	b.WriteString(s)
+	b.WriteString(ansiReset)
+}
+
+// consoleLevel returns the three-letter tag and the color of level
+func consoleLevel(level slog.Level) (string, string) {
+	switch {
+	case level < slog.LevelInfo:
+		return "DBG", ansiBlue
+	case level < slog.LevelWarn:
+		return "INF", ansiGreen
+	case level < slog.LevelError:
+		return "WRN", ansiYellow
+	default:
+		return "ERR", ansiRed
+	}
+}
+
+// consoleValue formats v, quoting strings that contain spaces, quotes or
+// control characters
+func consoleValue(v slog.Value) string {
+	var s string
+	switch v.Kind() {
+	case slog.KindTime:
+		s = v.Time().Format(time.RFC3339Nano)
+	case slog.KindDuration:
+		s = v.Duration().String()
+	default:
+		s = v.String()
+	}
+	if s == "" || strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r)
+	}) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
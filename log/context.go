@@ -0,0 +1,27 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+)
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying a logger with args added, as
+// key-value pairs or slog.Attr like slog.With, e.g. the order_id of the
+// request. The logger starts from the logger of ctx, so attributes added
+// along the request accumulate.
+func NewContext(ctx context.Context, args ...any) context.Context {
+	return context.WithValue(ctx, contextKey{}, FromContext(ctx).With(args...))
+}
+
+// FromContext returns the logger stored in ctx by NewContext, or
+// slog.Default() if none is set. Log with its Context methods, e.g.
+// FromContext(ctx).InfoContext(ctx, ...), so TraceHandler still adds the
+// trace fields of ctx.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,57 @@
+package log
+
+import (
+	"log/slog"
+	"strings"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// ECSVersion is the version of the Elastic Common Schema written by
+// ECSReplaceAttr, to be added to every record as ecs.version
+const ECSVersion = "8.11.0"
+
+// ecsKeys are the ECS fields of the attributes TraceHandler adds
+var ecsKeys = map[string]string{
+	DefaultTraceIDKey:                        "trace.id",
+	DefaultSpanIDKey:                         "span.id",
+	string(semconv.DeploymentEnvironmentKey): "service.environment",
+}
+
+// ECSReplaceAttr is a slog.HandlerOptions.ReplaceAttr for the JSON handler
+// that writes Elastic Common Schema fields: @timestamp, log.level, message
+// and log.origin instead of time, level, msg and source, and trace.id,
+// span.id and service.environment for the fields of TraceHandler, so logs
+// shipped to Elasticsearch are correlated with APM traces without an ingest
+// pipeline. trace_sampled has no ECS field and is dropped.
+func ECSReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.TimeKey:
+		return slog.Attr{Key: "@timestamp", Value: a.Value}
+	case slog.LevelKey:
+		return slog.String("log.level", strings.ToLower(a.Value.String()))
+	case slog.MessageKey:
+		return slog.String("message", a.Value.String())
+	case slog.SourceKey:
+		source, ok := a.Value.Any().(*slog.Source)
+		if !ok {
+			return a
+		}
+		return slog.Group("log.origin",
+			slog.Group("file",
+				slog.String("name", source.File),
+				slog.Int("line", source.Line),
+			),
+			slog.String("function", source.Function),
+		)
+	case DefaultTraceSampledKey:
+		return slog.Attr{}
+	}
+	if key, ok := ecsKeys[a.Key]; ok {
+		return slog.Attr{Key: key, Value: a.Value}
+	}
+	return a
+}
@@ -0,0 +1,269 @@
+package log
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultFileMaxSize is the size in bytes at which FileWriter rotates by
+// default
+const DefaultFileMaxSize = 100 << 20
+
+// backupTimeFormat is the timestamp of rotated files, sortable by name
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// FileWriterConfig holds configuration for FileWriter
+type FileWriterConfig struct {
+	// Path of the log file. Required.
+	Path string
+
+	// MaxSize rotates the file before it grows beyond this many bytes.
+	// Defaults to DefaultFileMaxSize.
+	MaxSize int64
+
+	// MaxAge rotates the file once it is this old, e.g. 24h for daily
+	// files. Optional.
+	MaxAge time.Duration
+
+	// MaxBackups is the number of rotated files kept; older ones are
+	// removed. All are kept when 0.
+	MaxBackups int
+
+	// Compress gzips the rotated files in the background
+	Compress bool
+
+	// OnError is called with the errors of compressing and removing the
+	// rotated files, which happen in the background. Optional.
+	OnError func(err error)
+}
+
+// FileWriter is an io.WriteCloser appending to a log file and rotating it
+// by size and age, for deployments where stdout is not collected. Rotated
+// files are renamed with a timestamp, e.g. app-2026-10-18T04-08-10.000.log,
+// and optionally compressed. Use it as the writer of a handler added as a
+// sink of MultiHandler.
+type FileWriter struct {
+	config FileWriterConfig
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+
+	background sync.WaitGroup
+}
+
+// NewFileWriter opens the log file, creating it and its directory if
+// needed, and returns a FileWriter appending to it
+func NewFileWriter(config *FileWriterConfig) (*FileWriter, error) {
+	var cfg FileWriterConfig
+	if config != nil {
+		cfg = *config
+	}
+	if cfg.Path == "" {
+		return nil, errors.New("log file path is required")
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultFileMaxSize
+	}
+
+	w := &FileWriter{config: cfg}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write appends p to the file, rotating it first when p would exceed
+// MaxSize or the file is older than MaxAge
+func (w *FileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	tooLarge := w.size > 0 && w.size+int64(len(p)) > w.config.MaxSize
+	tooOld := w.config.MaxAge > 0 && time.Since(w.opened) >= w.config.MaxAge
+	if tooLarge || tooOld {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate renames the current file and starts a new one
+func (w *FileWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	return w.rotate()
+}
+
+// Close closes the file and waits for the rotated files to be compressed
+func (w *FileWriter) Close() error {
+	w.mu.Lock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mu.Unlock()
+	w.background.Wait()
+	return err
+}
+
+// open opens the log file for appending. The age of an existing file is
+// counted from its last modification, so restarts do not postpone the
+// rotation.
+func (w *FileWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.config.Path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.config.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	w.opened = time.Now()
+	if info.Size() > 0 {
+		w.opened = info.ModTime()
+	}
+	return nil
+}
+
+// rotate renames the current file with a timestamp, opens a new one and
+// compresses and prunes the rotated files in the background
+func (w *FileWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+	backup := w.backupName(time.Now())
+	if err := os.Rename(w.config.Path, backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.background.Add(1)
+	go func() {
+		defer w.background.Done()
+		if w.config.Compress {
+			if err := compressFile(backup); err != nil {
+				w.reportError(err)
+			}
+		}
+		if err := w.prune(); err != nil {
+			w.reportError(err)
+		}
+	}()
+	return nil
+}
+
+// backupName returns the name of the file rotated at t: the timestamp is
+// inserted before the extension
+func (w *FileWriter) backupName(t time.Time) string {
+	ext := filepath.Ext(w.config.Path)
+	base := strings.TrimSuffix(w.config.Path, ext)
+	return base + "-" + t.Format(backupTimeFormat) + ext
+}
+
+// prune removes the oldest rotated files beyond MaxBackups
+func (w *FileWriter) prune() error {
+	if w.config.MaxBackups <= 0 {
+		return nil
+	}
+	ext := filepath.Ext(w.config.Path)
+	prefix := filepath.Base(strings.TrimSuffix(w.config.Path, ext)) + "-"
+	entries, err := os.ReadDir(filepath.Dir(w.config.Path))
+	if err != nil {
+		return err
+	}
+
+	var backups []string
+	for _, e := range entries {
+		name := e.Name()
+		stamp, ok := strings.CutPrefix(name, prefix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		stamp = strings.TrimSuffix(strings.TrimSuffix(stamp, ".gz"), ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			backups = append(backups, name)
+		}
+	}
+	if len(backups) <= w.config.MaxBackups {
+		return nil
+	}
+	sort.Strings(backups)
+
+	var errs []error
+	for _, name := range backups[:len(backups)-w.config.MaxBackups] {
+		err := os.Remove(filepath.Join(filepath.Dir(w.config.Path), name))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reportError passes err to OnError
+func (w *FileWriter) reportError(err error) {
+	if w.config.OnError != nil {
+		w.config.OnError(err)
+	}
+}
+
+// compressFile gzips path to path.gz and removes path
+func compressFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(path + ".gz")
+		}
+	}()
+
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,64 @@
+package log
+
+import (
+	"log/slog"
+	"strconv"
+)
+
+// Special fields of the Cloud Logging structured format
+const (
+	GCPTraceKey          = "logging.googleapis.com/trace"
+	GCPSpanIDKey         = "logging.googleapis.com/spanId"
+	GCPTraceSampledKey   = "logging.googleapis.com/trace_sampled"
+	GCPSourceLocationKey = "logging.googleapis.com/sourceLocation"
+)
+
+// GCPReplaceAttr is a slog.HandlerOptions.ReplaceAttr for the JSON handler
+// that writes the Cloud Logging structured format: severity instead of
+// level, message instead of msg and logging.googleapis.com/sourceLocation
+// instead of source. Combine it with TraceHandlerConfig.GCPProjectID for
+// the trace fields.
+func GCPReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.LevelKey:
+		level, _ := a.Value.Any().(slog.Level)
+		return slog.String("severity", gcpSeverity(level))
+	case slog.MessageKey:
+		return slog.String("message", a.Value.String())
+	case slog.SourceKey:
+		source, ok := a.Value.Any().(*slog.Source)
+		if !ok {
+			return a
+		}
+		return slog.Group(GCPSourceLocationKey,
+			slog.String("file", source.File),
+			slog.String("line", strconv.Itoa(source.Line)),
+			slog.String("function", source.Function),
+		)
+	}
+	return a
+}
+
+// gcpSeverity returns the Cloud Logging severity of level
+func gcpSeverity(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return "DEBUG"
+	case level < slog.LevelWarn:
+		return "INFO"
+	case level < slog.LevelError:
+		return "WARNING"
+	case level < slog.LevelError+4:
+		return "ERROR"
+	default:
+		return "CRITICAL"
+	}
+}
+
+// gcpTrace returns the logging.googleapis.com/trace value of traceID
+func gcpTrace(projectID, traceID string) string {
+	return "projects/" + projectID + "/traces/" + traceID
+}
@@ -2,8 +2,15 @@ package log
 
 import (
 	"context"
+	"encoding/binary"
 	"log/slog"
+	"strconv"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -12,13 +19,87 @@ const (
 	DefaultTraceIDKey      = "trace_id"
 	DefaultSpanIDKey       = "span_id"
 	DefaultTraceSampledKey = "trace_sampled"
+	DefaultTraceparentKey  = "traceparent"
 )
 
+// Datadog correlation keys
+const (
+	DatadogTraceIDKey = "dd.trace_id"
+	DatadogSpanIDKey  = "dd.span_id"
+	DatadogServiceKey = "dd.service"
+	DatadogEnvKey     = "dd.env"
+	DatadogVersionKey = "dd.version"
+)
+
+// ServiceResourceKeys are the resource attributes added to every record
+// with TraceHandlerConfig.Resource
+var ServiceResourceKeys = []attribute.Key{
+	semconv.ServiceNameKey,
+	semconv.ServiceVersionKey,
+	semconv.DeploymentEnvironmentKey,
+}
+
 // TraceHandlerConfig holds configuration for TraceHandler
 type TraceHandlerConfig struct {
 	TraceIDKey      string
 	SpanIDKey       string
 	TraceSampledKey string
+	TraceparentKey  string
+
+	// Traceparent also adds the W3C traceparent of the span, the same
+	// string the SQL comments carry, e.g.
+	// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01, so a log
+	// line can be pasted into a trace search or matched with the comments
+	// captured in the database logs
+	Traceparent bool
+
+	// Group nests the trace fields under a group instead of adding them at
+	// the top level, e.g. otel for {"otel":{"trace_id":...,"span_id":...}}.
+	// Optional.
+	Group string
+
+	// GCPProjectID writes the trace fields the way Cloud Logging correlates
+	// logs with traces instead, ignoring Group: logging.googleapis.com/trace
+	// as projects/<GCPProjectID>/traces/<trace ID>,
+	// logging.googleapis.com/spanId and logging.googleapis.com/trace_sampled.
+	// Use it with GCPReplaceAttr. Optional.
+	GCPProjectID string
+
+	// DatadogIDs also adds dd.trace_id and dd.span_id, the lower 64 bits of
+	// the IDs as decimal numbers, which Datadog uses to correlate logs with
+	// traces
+	DatadogIDs bool
+
+	// DatadogGroup adds the Datadog IDs as {"dd":{"trace_id":...,"span_id":...}}
+	// instead of the dd.trace_id and dd.span_id keys; Datadog reads both as
+	// the same attributes
+	DatadogGroup bool
+
+	// ErrorEvents records the records at slog.LevelError and above as events
+	// of the span of the context and sets its status to error, so failures
+	// that are only logged show up in the trace. An error attribute is
+	// recorded as an exception event.
+	ErrorEvents bool
+
+	// StackTraces adds the stack trace of the logging call as stack to the
+	// records at slog.LevelError and above that do not have one, trimmed
+	// to MaxStackFrames frames (see Stack). With ErrorEvents, it is also
+	// recorded as exception.stacktrace.
+	StackTraces bool
+
+	// MaxStackFrames defaults to DefaultMaxStackFrames
+	MaxStackFrames int
+
+	// BaggageKeys lists the baggage members of the context added to the
+	// record, keyed by the member key, e.g. tenant. Members not listed are
+	// never logged since baggage comes from the caller.
+	BaggageKeys []string
+
+	// Resource adds its ServiceResourceKeys to every record, so logs can be
+	// filtered by the unified service tags wherever they are shipped. With
+	// DatadogIDs, they are also added as dd.service, dd.env and dd.version.
+	// Optional.
+	Resource *resource.Resource
 }
 
 // TraceHandler is a slog.Handler that adds trace ID and span ID to the record
@@ -33,6 +114,7 @@ func NewTraceHandler(h slog.Handler, config *TraceHandlerConfig) *TraceHandler {
 		TraceIDKey:      DefaultTraceIDKey,
 		SpanIDKey:       DefaultSpanIDKey,
 		TraceSampledKey: DefaultTraceSampledKey,
+		TraceparentKey:  DefaultTraceparentKey,
 	}
 	if config != nil {
 		if config.TraceIDKey != "" {
@@ -44,6 +126,23 @@ func NewTraceHandler(h slog.Handler, config *TraceHandlerConfig) *TraceHandler {
 		if config.TraceSampledKey != "" {
 			cfg.TraceSampledKey = config.TraceSampledKey
 		}
+		if config.TraceparentKey != "" {
+			cfg.TraceparentKey = config.TraceparentKey
+		}
+		cfg.Traceparent = config.Traceparent
+		cfg.Group = config.Group
+		cfg.GCPProjectID = config.GCPProjectID
+		cfg.DatadogIDs = config.DatadogIDs
+		cfg.DatadogGroup = config.DatadogGroup
+		cfg.ErrorEvents = config.ErrorEvents
+		cfg.StackTraces = config.StackTraces
+		cfg.MaxStackFrames = config.MaxStackFrames
+		cfg.BaggageKeys = config.BaggageKeys
+		cfg.Resource = config.Resource
+	}
+
+	if cfg.Resource != nil {
+		h = h.WithAttrs(resourceAttrs(cfg.Resource, cfg.DatadogIDs))
 	}
 
 	return &TraceHandler{
@@ -52,20 +151,167 @@ func NewTraceHandler(h slog.Handler, config *TraceHandlerConfig) *TraceHandler {
 	}
 }
 
-// Handle adds trace_id and span_id to the record if a span is found in the context
+// resourceAttrs returns the service attributes of res
+func resourceAttrs(res *resource.Resource, datadog bool) []slog.Attr {
+	ddKeys := map[attribute.Key]string{
+		semconv.ServiceNameKey:           DatadogServiceKey,
+		semconv.ServiceVersionKey:        DatadogVersionKey,
+		semconv.DeploymentEnvironmentKey: DatadogEnvKey,
+	}
+	var attrs []slog.Attr
+	for _, key := range ServiceResourceKeys {
+		value, ok := res.Set().Value(key)
+		if !ok {
+			continue
+		}
+		attrs = append(attrs, slog.String(string(key), value.Emit()))
+		if ddKey, ok := ddKeys[key]; ok && datadog {
+			attrs = append(attrs, slog.String(ddKey, value.Emit()))
+		}
+	}
+	return attrs
+}
+
+// Handle adds trace_id and span_id to the record if a span is found in the
+// context, and the allowlisted baggage members
 func (h *TraceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if len(h.config.BaggageKeys) > 0 {
+		b := baggage.FromContext(ctx)
+		for _, key := range h.config.BaggageKeys {
+			if m := b.Member(key); m.Key() != "" {
+				r.AddAttrs(slog.String(key, m.Value()))
+			}
+		}
+	}
+
+	if h.config.StackTraces && r.Level >= slog.LevelError && !hasAttr(r, StackKey) {
+		r.AddAttrs(slog.String(StackKey, Stack(h.config.MaxStackFrames)))
+	}
+
 	span := trace.SpanFromContext(ctx)
-	if span.SpanContext().IsValid() {
-		// Add trace_id and span_id attributes
+	if h.config.ErrorEvents && r.Level >= slog.LevelError && span.IsRecording() {
+		recordError(span, r)
+	}
+	sc := span.SpanContext()
+	switch {
+	case !sc.IsValid():
+	case h.config.GCPProjectID != "":
+		// Add the Cloud Logging trace fields
 		r.AddAttrs(
-			slog.String(h.config.TraceIDKey, span.SpanContext().TraceID().String()),
-			slog.String(h.config.SpanIDKey, span.SpanContext().SpanID().String()),
-			slog.Bool(h.config.TraceSampledKey, span.SpanContext().TraceFlags().IsSampled()),
+			slog.String(GCPTraceKey, gcpTrace(h.config.GCPProjectID, sc.TraceID().String())),
+			slog.String(GCPSpanIDKey, sc.SpanID().String()),
+			slog.Bool(GCPTraceSampledKey, sc.TraceFlags().IsSampled()),
 		)
+		if h.config.Traceparent {
+			r.AddAttrs(slog.String(h.config.TraceparentKey, traceparent(sc)))
+		}
+	default:
+		// Add trace_id and span_id attributes
+		attrs := []slog.Attr{
+			slog.String(h.config.TraceIDKey, sc.TraceID().String()),
+			slog.String(h.config.SpanIDKey, sc.SpanID().String()),
+			slog.Bool(h.config.TraceSampledKey, sc.TraceFlags().IsSampled()),
+		}
+		if h.config.Traceparent {
+			attrs = append(attrs, slog.String(h.config.TraceparentKey, traceparent(sc)))
+		}
+		addAttrs(&r, h.config.Group, attrs...)
+		if h.config.DatadogIDs {
+			tid := sc.TraceID()
+			sid := sc.SpanID()
+			traceID := strconv.FormatUint(binary.BigEndian.Uint64(tid[8:]), 10)
+			spanID := strconv.FormatUint(binary.BigEndian.Uint64(sid[:]), 10)
+			if h.config.DatadogGroup {
+				addAttrs(&r, "dd", slog.String("trace_id", traceID), slog.String("span_id", spanID))
+			} else {
+				r.AddAttrs(slog.String(DatadogTraceIDKey, traceID), slog.String(DatadogSpanIDKey, spanID))
+			}
+		}
 	}
 	return h.Handler.Handle(ctx, r)
 }
 
+// traceparent returns the W3C traceparent of sc
+func traceparent(sc trace.SpanContext) string {
+	return "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-" + sc.TraceFlags().String()
+}
+
+// hasAttr reports whether r has a top-level attribute with key
+func hasAttr(r slog.Record, key string) bool {
+	found := false
+	r.Attrs(func(a slog.Attr) bool {
+		found = a.Key == key
+		return !found
+	})
+	return found
+}
+
+// recordError records r as an event of span and sets its status to error
+func recordError(span trace.Span, r slog.Record) {
+	attrs := []attribute.KeyValue{
+		attribute.String("log.severity", r.Level.String()),
+		attribute.String("log.message", r.Message),
+	}
+	var err error
+	r.Attrs(func(a slog.Attr) bool {
+		if e, ok := a.Value.Resolve().Any().(error); ok && err == nil {
+			err = e
+			return true
+		}
+		if a.Key == StackKey {
+			attrs = append(attrs, semconv.ExceptionStacktrace(a.Value.String()))
+			return true
+		}
+		attrs = appendAttribute(attrs, "", a)
+		return true
+	})
+
+	if err != nil {
+		span.RecordError(err, trace.WithAttributes(attrs...))
+	} else {
+		span.AddEvent("log", trace.WithAttributes(attrs...))
+	}
+	span.SetStatus(codes.Error, r.Message)
+}
+
+// appendAttribute appends a as span attributes, flattening groups into
+// dotted keys
+func appendAttribute(attrs []attribute.KeyValue, prefix string, a slog.Attr) []attribute.KeyValue {
+	key := prefix + a.Key
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return append(attrs, attribute.String(key, v.String()))
+	case slog.KindInt64:
+		return append(attrs, attribute.Int64(key, v.Int64()))
+	case slog.KindUint64:
+		return append(attrs, attribute.Int64(key, int64(v.Uint64())))
+	case slog.KindFloat64:
+		return append(attrs, attribute.Float64(key, v.Float64()))
+	case slog.KindBool:
+		return append(attrs, attribute.Bool(key, v.Bool()))
+	case slog.KindGroup:
+		if a.Key != "" {
+			prefix = key + "."
+		}
+		for _, ga := range v.Group() {
+			attrs = appendAttribute(attrs, prefix, ga)
+		}
+		return attrs
+	default:
+		return append(attrs, attribute.String(key, v.String()))
+	}
+}
+
+// addAttrs adds attrs to r, nested under group unless it is empty
+func addAttrs(r *slog.Record, group string, attrs ...slog.Attr) {
+	if group == "" {
+		r.AddAttrs(attrs...)
+		return
+	}
+	r.AddAttrs(slog.Attr{Key: group, Value: slog.GroupValue(attrs...)})
+}
+
 // WithAttrs returns a new TraceHandler with attributes added to the underlying handler
 func (h *TraceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	return &TraceHandler{
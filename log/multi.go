@@ -0,0 +1,124 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// Sink is a handler of MultiHandler with its own minimum level
+type Sink struct {
+	// Name identifies the sink in errors, e.g. stdout or otlp
+	Name string
+
+	// Handler writes the records. Required.
+	Handler slog.Handler
+
+	// Level is the minimum level of the records written to the sink, in
+	// addition to the level of Handler. Optional.
+	Level slog.Leveler
+}
+
+// MultiHandlerConfig holds configuration for MultiHandler
+type MultiHandlerConfig struct {
+	Sinks []Sink
+
+	// OnError is called with the error or recovered panic of a sink. Logging
+	// it through the same handler would loop, so report it elsewhere, e.g. to
+	// stderr. Optional.
+	OnError func(sink string, err error)
+}
+
+// MultiHandler is a slog.Handler that writes each record to several sinks,
+// e.g. stdout and the OpenTelemetry Logs SDK. The sinks are isolated from
+// each other: a sink that fails or panics does not keep the record from
+// the others.
+type MultiHandler struct {
+	sinks   []Sink
+	onError func(sink string, err error)
+}
+
+// NewMultiHandler creates a new MultiHandler
+func NewMultiHandler(config *MultiHandlerConfig) *MultiHandler {
+	var cfg MultiHandlerConfig
+	if config != nil {
+		cfg = *config
+	}
+	return &MultiHandler{
+		sinks:   cfg.Sinks,
+		onError: cfg.OnError,
+	}
+}
+
+// Enabled reports whether any sink handles records at level l
+func (h *MultiHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	for _, s := range h.sinks {
+		if s.enabled(ctx, l) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle writes the record to every sink enabled at its level and returns
+// the errors of the sinks
+func (h *MultiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for i, s := range h.sinks {
+		if !s.enabled(ctx, r.Level) {
+			continue
+		}
+		// Each sink gets its own copy so attributes added by one sink do
+		// not leak into the next
+		record := r
+		if i < len(h.sinks)-1 {
+			record = r.Clone()
+		}
+		if err := s.handle(ctx, record); err != nil {
+			if h.onError != nil {
+				h.onError(s.Name, err)
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithAttrs returns a new MultiHandler with attributes added to every sink
+func (h *MultiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	sinks := make([]Sink, len(h.sinks))
+	for i, s := range h.sinks {
+		s.Handler = s.Handler.WithAttrs(attrs)
+		sinks[i] = s
+	}
+	return &MultiHandler{sinks: sinks, onError: h.onError}
+}
+
+// WithGroup returns a new MultiHandler with a group added to every sink
+func (h *MultiHandler) WithGroup(name string) slog.Handler {
+	sinks := make([]Sink, len(h.sinks))
+	for i, s := range h.sinks {
+		s.Handler = s.Handler.WithGroup(name)
+		sinks[i] = s
+	}
+	return &MultiHandler{sinks: sinks, onError: h.onError}
+}
+
+// enabled reports whether the sink handles records at level l
+func (s Sink) enabled(ctx context.Context, l slog.Level) bool {
+	if s.Level != nil && l < s.Level.Level() {
+		return false
+	}
+	return s.Handler.Enabled(ctx, l)
+}
+
+// handle writes the record to the sink, turning a panic into an error
+func (s Sink) handle(ctx context.Context, r slog.Record) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return s.Handler.Handle(ctx, r)
+}
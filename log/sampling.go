@@ -0,0 +1,143 @@
+package log
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// samplingCounters is the number of counters identical messages are counted
+// with; distinct messages sharing a counter are sampled together
+const samplingCounters = 4096
+
+// SamplingHandlerConfig holds configuration for SamplingHandler
+type SamplingHandlerConfig struct {
+	// Tick is the period the counts of each message are reset after.
+	// Defaults to 1s.
+	Tick time.Duration
+
+	// First is the number of records of each message written per tick.
+	// Defaults to 100.
+	First int
+
+	// Thereafter writes every Thereafter-th record of a message after the
+	// first ones of the tick; 0 drops them all
+	Thereafter int
+
+	// KeepLevel is the level from which records are never sampled. Defaults
+	// to slog.LevelWarn.
+	KeepLevel slog.Leveler
+}
+
+// SamplingHandler is a slog.Handler that rate-limits identical messages: per
+// tick, it writes the first records with the same level and message and then
+// only every Thereafter-th one, so a message logged on every query does not
+// flood the output under load. Records at KeepLevel and above are always
+// written.
+type SamplingHandler struct {
+	slog.Handler
+	state *samplingState
+}
+
+type samplingState struct {
+	config   SamplingHandlerConfig
+	counters [samplingCounters]samplingCounter
+	dropped  atomic.Int64
+}
+
+// NewSamplingHandler creates a new SamplingHandler
+func NewSamplingHandler(h slog.Handler, config *SamplingHandlerConfig) *SamplingHandler {
+	cfg := SamplingHandlerConfig{
+		Tick:      time.Second,
+		First:     100,
+		KeepLevel: slog.LevelWarn,
+	}
+	if config != nil {
+		if config.Tick > 0 {
+			cfg.Tick = config.Tick
+		}
+		if config.First > 0 {
+			cfg.First = config.First
+		}
+		cfg.Thereafter = max(config.Thereafter, 0)
+		if config.KeepLevel != nil {
+			cfg.KeepLevel = config.KeepLevel
+		}
+	}
+	return &SamplingHandler{
+		Handler: h,
+		state:   &samplingState{config: cfg},
+	}
+}
+
+// Dropped returns the number of records dropped so far
+func (h *SamplingHandler) Dropped() int64 {
+	return h.state.dropped.Load()
+}
+
+// Handle writes the record unless its message already reached the limit of
+// the tick
+func (h *SamplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	cfg := h.state.config
+	if r.Level >= cfg.KeepLevel.Level() {
+		return h.Handler.Handle(ctx, r)
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(r.Level.String()))
+	hash.Write([]byte(r.Message))
+	counter := &h.state.counters[hash.Sum32()%samplingCounters]
+
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	n := counter.inc(t, cfg.Tick)
+	if n <= int64(cfg.First) || cfg.Thereafter > 0 && (n-int64(cfg.First))%int64(cfg.Thereafter) == 0 {
+		return h.Handler.Handle(ctx, r)
+	}
+	h.state.dropped.Add(1)
+	return nil
+}
+
+// WithAttrs returns a new SamplingHandler with attributes added to the
+// underlying handler, sharing the counts
+func (h *SamplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SamplingHandler{
+		Handler: h.Handler.WithAttrs(attrs),
+		state:   h.state,
+	}
+}
+
+// WithGroup returns a new SamplingHandler with a group added to the
+// underlying handler, sharing the counts
+func (h *SamplingHandler) WithGroup(name string) slog.Handler {
+	return &SamplingHandler{
+		Handler: h.Handler.WithGroup(name),
+		state:   h.state,
+	}
+}
+
+// samplingCounter counts the records of a message in the current tick
+type samplingCounter struct {
+	resetAt atomic.Int64
+	count   atomic.Int64
+}
+
+// inc counts a record at t and returns the count of the tick, starting a new
+// tick when the current one is over
+func (c *samplingCounter) inc(t time.Time, tick time.Duration) int64 {
+	now := t.UnixNano()
+	resetAt := c.resetAt.Load()
+	if now < resetAt {
+		return c.count.Add(1)
+	}
+	c.count.Store(1)
+	if !c.resetAt.CompareAndSwap(resetAt, now+tick.Nanoseconds()) {
+		// Another record started the tick
+		return c.count.Add(1)
+	}
+	return 1
+}
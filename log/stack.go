@@ -0,0 +1,68 @@
+package log
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// StackKey is the attribute of the stack trace added with
+// TraceHandlerConfig.StackTraces
+const StackKey = "stack"
+
+// DefaultMaxStackFrames is the number of frames kept by default
+const DefaultMaxStackFrames = 32
+
+// Packages of the frames leading to the logging call, left out of stack
+// traces
+var loggingPackages = []string{"runtime.", "log/slog.", "otel-go-dbm/log."}
+
+// Stack returns the stack trace of the calling goroutine in the format of
+// runtime/debug.Stack, trimmed for logs: the frames of log/slog and of
+// this package before the logging call and the frames of the runtime are
+// left out, and at most maxFrames frames are kept (DefaultMaxStackFrames
+// when maxFrames <= 0). Called from a deferred function during a panic,
+// e.g. to log a recovered panic, the trace includes the panicking frames.
+func Stack(maxFrames int) string {
+	if maxFrames <= 0 {
+		maxFrames = DefaultMaxStackFrames
+	}
+	// Room for the leading frames of the logging call
+	pcs := make([]uintptr, maxFrames+32)
+	pcs = pcs[:runtime.Callers(2, pcs)]
+	frames := runtime.CallersFrames(pcs)
+
+	var b strings.Builder
+	leading, n := true, 0
+	for n < maxFrames {
+		frame, more := frames.Next()
+		skip := strings.HasPrefix(frame.Function, "runtime.")
+		if leading {
+			skip = hasAnyPrefix(frame.Function, loggingPackages)
+			leading = skip
+		}
+		if !skip {
+			b.WriteString(frame.Function)
+			b.WriteString("\n\t")
+			b.WriteString(frame.File)
+			b.WriteByte(':')
+			b.WriteString(strconv.Itoa(frame.Line))
+			b.WriteByte('\n')
+			n++
+		}
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// hasAnyPrefix reports whether s starts with any of prefixes
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
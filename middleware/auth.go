@@ -0,0 +1,266 @@
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultAPIKeyHeader is the header of API keys
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// PrincipalBaggageKey is the baggage member of the principal ID. Add it to
+// log.TraceHandlerConfig.BaggageKeys to log the principal.
+const PrincipalBaggageKey = "enduser.id"
+
+// AttrAuthMethod is the span attribute of the authentication method
+const AttrAuthMethod = attribute.Key("enduser.auth_method")
+
+// Authentication methods of a Principal
+const (
+	AuthMethodAPIKey = "api_key"
+	AuthMethodJWT    = "jwt"
+)
+
+// Errors passed to AuthConfig.Respond, wrapped with the reason
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type principalKey struct{}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	// ID identifies the caller: the name of the API key or the subject of
+	// the JWT
+	ID string
+
+	// Method is AuthMethodAPIKey or AuthMethodJWT
+	Method string
+}
+
+// AuthConfig holds configuration for Auth. At least one of APIKeys and
+// JWTSecret must be set for any request to be accepted.
+type AuthConfig struct {
+	// APIKeys maps accepted API keys to the ID of their principal
+	APIKeys map[string]string
+
+	// APIKeyHeader carries the API key. Defaults to DefaultAPIKeyHeader.
+	APIKeyHeader string
+
+	// JWTSecret verifies HS256 bearer tokens in the Authorization header
+	JWTSecret []byte
+
+	// JWTIssuer and JWTAudience are the required iss and aud claims.
+	// Optional.
+	JWTIssuer   string
+	JWTAudience string
+
+	// Leeway tolerates clock skew in the exp and nbf claims
+	Leeway time.Duration
+
+	// Respond writes the response of an unauthenticated request, after the
+	// WWW-Authenticate header is set. Defaults to a JSON 401 with the code
+	// UNAUTHORIZED.
+	Respond func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// Auth returns a Middleware that rejects requests without a valid API key
+// or JWT. The principal of accepted requests is set as enduser.id on the
+// span of the request and as the enduser.id member of the baggage, so it
+// reaches the logs, and stored in the context (see PrincipalFromContext).
+//
+// Add it inside the tracing middleware so the span of the request is in
+// the context.
+func Auth(config *AuthConfig) Middleware {
+	cfg := AuthConfig{
+		APIKeyHeader: DefaultAPIKeyHeader,
+		Respond:      respondUnauthorized,
+	}
+	if config != nil {
+		cfg.APIKeys = config.APIKeys
+		if config.APIKeyHeader != "" {
+			cfg.APIKeyHeader = config.APIKeyHeader
+		}
+		cfg.JWTSecret = config.JWTSecret
+		cfg.JWTIssuer = config.JWTIssuer
+		cfg.JWTAudience = config.JWTAudience
+		cfg.Leeway = config.Leeway
+		if config.Respond != nil {
+			cfg.Respond = config.Respond
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			span := trace.SpanFromContext(ctx)
+			p, err := cfg.authenticate(r)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				cfg.Respond(w, r, err)
+				return
+			}
+
+			span.SetAttributes(semconv.EnduserID(p.ID), AttrAuthMethod.String(p.Method))
+			if m, err := baggage.NewMemberRaw(PrincipalBaggageKey, p.ID); err == nil {
+				if b, err := baggage.FromContext(ctx).SetMember(m); err == nil {
+					ctx = baggage.ContextWithBaggage(ctx, b)
+				}
+			}
+			ctx = context.WithValue(ctx, principalKey{}, p)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// PrincipalFromContext returns the principal stored in ctx by Auth
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// authenticate returns the principal of the API key or bearer token of r
+func (c *AuthConfig) authenticate(r *http.Request) (Principal, error) {
+	if key := r.Header.Get(c.APIKeyHeader); key != "" {
+		// Every key is compared so the time does not tell which one matched
+		var id string
+		for k, v := range c.APIKeys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+				id = v
+			}
+		}
+		if id == "" {
+			return Principal{}, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+		}
+		return Principal{ID: id, Method: AuthMethodAPIKey}, nil
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return Principal{}, ErrNoCredentials
+	}
+	if len(c.JWTSecret) == 0 {
+		return Principal{}, fmt.Errorf("%w: bearer tokens are not accepted", ErrInvalidCredentials)
+	}
+	sub, err := c.verifyJWT(token, time.Now())
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+	return Principal{ID: sub, Method: AuthMethodJWT}, nil
+}
+
+// jwtClaims are the registered claims checked by verifyJWT
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *float64        `json:"exp"`
+	NotBefore *float64        `json:"nbf"`
+}
+
+// verifyJWT checks the HS256 signature and the claims of token at now and
+// returns its subject
+func (c *AuthConfig) verifyJWT(token string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return "", fmt.Errorf("malformed header: %w", err)
+	}
+	// Only the configured algorithm is accepted, never "none"
+	if header.Alg != "HS256" {
+		return "", fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed signature: %w", err)
+	}
+	mac := hmac.New(sha256.New, c.JWTSecret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", errors.New("invalid signature")
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return "", fmt.Errorf("malformed claims: %w", err)
+	}
+	if claims.ExpiresAt == nil {
+		return "", errors.New("missing exp claim")
+	}
+	if now.After(unixTime(*claims.ExpiresAt).Add(c.Leeway)) {
+		return "", errors.New("token is expired")
+	}
+	if claims.NotBefore != nil && now.Before(unixTime(*claims.NotBefore).Add(-c.Leeway)) {
+		return "", errors.New("token is not valid yet")
+	}
+	if c.JWTIssuer != "" && claims.Issuer != c.JWTIssuer {
+		return "", fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if c.JWTAudience != "" && !hasAudience(claims.Audience, c.JWTAudience) {
+		return "", errors.New("unexpected audience")
+	}
+	if claims.Subject == "" {
+		return "", errors.New("missing sub claim")
+	}
+	return claims.Subject, nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a JWT into v
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// hasAudience reports whether the aud claim, a string or an array of
+// strings, contains want
+func hasAudience(aud json.RawMessage, want string) bool {
+	var one string
+	if json.Unmarshal(aud, &one) == nil {
+		return one == want
+	}
+	var many []string
+	if json.Unmarshal(aud, &many) == nil {
+		for _, a := range many {
+			if a == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unixTime converts a NumericDate claim to a time
+func unixTime(sec float64) time.Time {
+	return time.Unix(0, int64(sec*float64(time.Second)))
+}
+
+// respondUnauthorized writes the JSON error response of the API
+func respondUnauthorized(w http.ResponseWriter, _ *http.Request, _ error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	fmt.Fprint(w, `{"success":false,"error":{"code":"UNAUTHORIZED","message":"Authentication required"}}`+"\n")
+}
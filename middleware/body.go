@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// DefaultMaxBodyBytes is the request body limit of BodyLimit by default
+const DefaultMaxBodyBytes = 1 << 20
+
+// BodyLimitConfig holds configuration for BodyLimit
+type BodyLimitConfig struct {
+	// MaxBytes is the size limit of request bodies. Defaults to
+	// DefaultMaxBodyBytes.
+	MaxBytes int64
+
+	// Respond writes the response of a request whose Content-Length
+	// exceeds the limit. Defaults to a JSON 413 with the code
+	// BODY_TOO_LARGE.
+	Respond func(w http.ResponseWriter, r *http.Request, maxBytes int64)
+}
+
+// BodyLimit returns a Middleware that limits the size of request bodies,
+// so a client cannot make the server buffer an unbounded body. Requests
+// declaring a larger Content-Length are rejected before next is called;
+// otherwise the body is wrapped with http.MaxBytesReader, whose reads fail
+// with *http.MaxBytesError past the limit.
+func BodyLimit(config *BodyLimitConfig) Middleware {
+	cfg := BodyLimitConfig{
+		MaxBytes: DefaultMaxBodyBytes,
+		Respond:  respondBodyTooLarge,
+	}
+	if config != nil {
+		if config.MaxBytes > 0 {
+			cfg.MaxBytes = config.MaxBytes
+		}
+		if config.Respond != nil {
+			cfg.Respond = config.Respond
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > cfg.MaxBytes {
+				w.Header().Set("Connection", "close")
+				cfg.Respond(w, r, cfg.MaxBytes)
+				return
+			}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxBytes)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// respondBodyTooLarge writes the JSON error response of the API
+func respondBodyTooLarge(w http.ResponseWriter, _ *http.Request, maxBytes int64) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	fmt.Fprintf(w, `{"success":false,"error":{"code":"BODY_TOO_LARGE","message":"Request body must not exceed %d bytes"}}`+"\n", maxBytes)
+}
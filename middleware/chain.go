@@ -0,0 +1,60 @@
+// Package middleware composes HTTP middleware in a fixed, readable order.
+// Tracing, logging, recovery, auth, rate limiting and the like are each a
+// Middleware; a Chain lists them from the outermost to the innermost
+// instead of nesting the calls by hand.
+package middleware
+
+import "net/http"
+
+// Middleware wraps a handler, e.g. to record, check or change requests
+type Middleware func(http.Handler) http.Handler
+
+// Chain is an ordered list of middleware. The first one added is the
+// outermost: it sees the request first and the response last.
+type Chain struct {
+	middlewares []Middleware
+}
+
+// NewChain creates a Chain of mws, the first being the outermost
+func NewChain(mws ...Middleware) *Chain {
+	c := &Chain{}
+	c.Use(mws...)
+	return c
+}
+
+// Use appends mws inside the middleware already in the chain. Nil entries
+// are skipped, so optional middleware can be added unconditionally.
+func (c *Chain) Use(mws ...Middleware) *Chain {
+	for _, mw := range mws {
+		if mw != nil {
+			c.middlewares = append(c.middlewares, mw)
+		}
+	}
+	return c
+}
+
+// Clone returns a copy of the chain, to extend it without changing c
+func (c *Chain) Clone() *Chain {
+	return &Chain{middlewares: append([]Middleware(nil), c.middlewares...)}
+}
+
+// Then returns h wrapped with the middleware of the chain
+func (c *Chain) Then(h http.Handler) http.Handler {
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		h = c.middlewares[i](h)
+	}
+	return h
+}
+
+// ThenFunc returns fn wrapped with the middleware of the chain
+func (c *Chain) ThenFunc(fn http.HandlerFunc) http.Handler {
+	return c.Then(fn)
+}
+
+// Compose returns a Middleware applying mws in order, the first being the
+// outermost
+func Compose(mws ...Middleware) Middleware {
+	return func(h http.Handler) http.Handler {
+		return NewChain(mws...).Then(h)
+	}
+}
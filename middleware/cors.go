@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of CORSConfig
+var (
+	DefaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	DefaultCORSHeaders = []string{"Authorization", "Content-Type", DefaultAPIKeyHeader, DefaultRequestIDHeader}
+	DefaultCORSExposed = []string{DefaultRequestIDHeader, "Retry-After"}
+)
+
+// DefaultCORSMaxAge is how long browsers cache preflight responses by
+// default
+const DefaultCORSMaxAge = 10 * time.Minute
+
+// CORSConfig holds configuration for CORS
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed to call the API, e.g.
+	// https://dashboard.example.com. "*" allows any origin, and a "*" in
+	// the host allows its subdomains, e.g. https://*.example.com.
+	AllowedOrigins []string
+
+	// AllowedMethods are the methods allowed in cross-origin requests.
+	// Defaults to DefaultCORSMethods.
+	AllowedMethods []string
+
+	// AllowedHeaders are the request headers allowed in cross-origin
+	// requests; "*" allows any. Defaults to DefaultCORSHeaders.
+	AllowedHeaders []string
+
+	// ExposedHeaders are the response headers readable by the browser.
+	// Defaults to DefaultCORSExposed.
+	ExposedHeaders []string
+
+	// AllowCredentials lets browsers send cookies and HTTP authentication.
+	// The origin is then echoed instead of "*".
+	AllowCredentials bool
+
+	// MaxAge is how long browsers cache preflight responses. Defaults to
+	// DefaultCORSMaxAge.
+	MaxAge time.Duration
+}
+
+// CORS returns a Middleware answering CORS preflight requests and adding
+// the CORS headers to the responses of allowed origins, so browsers on
+// other origins can call the API. Preflight requests are answered with a
+// 204 without calling next; the headers are left out for disallowed
+// origins, methods and headers, which the browser then blocks.
+//
+// Add it outside the ServeMux and authentication, as preflight requests
+// use the OPTIONS method and carry no credentials.
+func CORS(config *CORSConfig) Middleware {
+	cfg := CORSConfig{
+		AllowedMethods: DefaultCORSMethods,
+		AllowedHeaders: DefaultCORSHeaders,
+		ExposedHeaders: DefaultCORSExposed,
+		MaxAge:         DefaultCORSMaxAge,
+	}
+	if config != nil {
+		cfg.AllowedOrigins = config.AllowedOrigins
+		if len(config.AllowedMethods) > 0 {
+			cfg.AllowedMethods = config.AllowedMethods
+		}
+		if len(config.AllowedHeaders) > 0 {
+			cfg.AllowedHeaders = config.AllowedHeaders
+		}
+		if len(config.ExposedHeaders) > 0 {
+			cfg.ExposedHeaders = config.ExposedHeaders
+		}
+		cfg.AllowCredentials = config.AllowCredentials
+		if config.MaxAge > 0 {
+			cfg.MaxAge = config.MaxAge
+		}
+	}
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+	anyHeader := slices.Contains(cfg.AllowedHeaders, "*")
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	// allowOrigin sets the allowed origin of the response
+	allowOrigin := func(h http.Header, origin string) {
+		if anyOrigin && !cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if !anyOrigin || cfg.AllowCredentials {
+				h.Add("Vary", "Origin")
+			}
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			allowed := anyOrigin || matchOrigin(cfg.AllowedOrigins, origin)
+
+			if !preflight {
+				if allowed {
+					allowOrigin(h, origin)
+					if exposed != "" {
+						h.Set("Access-Control-Expose-Headers", exposed)
+					}
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			method := r.Header.Get("Access-Control-Request-Method")
+			requested := r.Header.Get("Access-Control-Request-Headers")
+			if allowed && slices.Contains(cfg.AllowedMethods, method) &&
+				(anyHeader || allowHeaders(cfg.AllowedHeaders, requested)) {
+				allowOrigin(h, origin)
+				h.Set("Access-Control-Allow-Methods", methods)
+				if requested != "" {
+					// The requested headers are allowed, so they are echoed
+					h.Set("Access-Control-Allow-Headers", requested)
+				}
+				h.Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// matchOrigin reports whether origin is one of allowed, where a "*" in an
+// allowed origin matches any subdomain
+func matchOrigin(allowed []string, origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if a == origin {
+			return true
+		}
+		if prefix, suffix, ok := strings.Cut(a, "*"); ok &&
+			len(origin) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) &&
+			!strings.ContainsAny(origin[len(prefix):len(origin)-len(suffix)], "/:") {
+			return true
+		}
+	}
+	return false
+}
+
+// allowHeaders reports whether every header of the comma-separated list
+// requested is one of allowed, ignoring case
+func allowHeaders(allowed []string, requested string) bool {
+	for _, name := range strings.Split(requested, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.ContainsFunc(allowed, func(a string) bool { return strings.EqualFold(a, name) }) {
+			return false
+		}
+	}
+	return true
+}
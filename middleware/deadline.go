@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span attributes recorded by Deadline
+const (
+	AttrDeadlineExceeded   = attribute.Key("http.request.deadline_exceeded")
+	AttrClientDisconnected = attribute.Key("http.request.client_disconnected")
+	AttrRequestTimeout     = attribute.Key("http.request.timeout_ms")
+)
+
+// DeadlineConfig holds configuration for Deadline
+type DeadlineConfig struct {
+	// Timeout bounds the handling of a request. Deadline returns nil when
+	// it is not positive.
+	Timeout time.Duration
+
+	// Respond writes the response of a request whose deadline passed before
+	// the handler wrote its response. Defaults to a JSON 504 with the code
+	// DEADLINE_EXCEEDED.
+	Respond func(w http.ResponseWriter, r *http.Request)
+}
+
+// Deadline returns a Middleware that sets a deadline of Timeout on the
+// context of the request. The context is also cancelled when the client
+// disconnects, so database calls made with it (QueryContext, ExecContext)
+// are cancelled instead of running for nobody.
+//
+// Unlike http.TimeoutHandler, next runs on the goroutine of the request and
+// is not abandoned: Deadline waits for it to return, so handlers must pass
+// the context on. Once the deadline has passed, a response the handler has
+// not started yet, typically its error for the cancelled query, is replaced
+// by Respond. The span of the request records deadline_exceeded or
+// client_disconnected.
+func Deadline(config *DeadlineConfig) Middleware {
+	cfg := DeadlineConfig{Respond: respondDeadlineExceeded}
+	if config != nil {
+		cfg.Timeout = config.Timeout
+		if config.Respond != nil {
+			cfg.Respond = config.Respond
+		}
+	}
+	if cfg.Timeout <= 0 {
+		return nil
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			parent := r.Context()
+			ctx, cancel := context.WithTimeout(parent, cfg.Timeout)
+			defer cancel()
+
+			dw := &deadlineWriter{ResponseWriter: w, ctx: ctx}
+			next.ServeHTTP(dw, r.WithContext(ctx))
+
+			span := trace.SpanFromContext(ctx)
+			switch {
+			case parent.Err() != nil:
+				// Nobody reads the response anymore
+				span.SetAttributes(AttrClientDisconnected.Bool(true))
+			case errors.Is(ctx.Err(), context.DeadlineExceeded):
+				span.SetAttributes(
+					AttrDeadlineExceeded.Bool(true),
+					AttrRequestTimeout.Int64(cfg.Timeout.Milliseconds()),
+				)
+				// A response written in time is not replaced and not an error
+				if dw.suppressed || !dw.wroteHeader {
+					span.SetStatus(codes.Error, "request deadline exceeded")
+					cfg.Respond(w, r)
+				}
+			}
+		})
+	}
+}
+
+// deadlineWriter discards the response of the handler when it is started
+// after the deadline of ctx
+type deadlineWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	wroteHeader bool
+	suppressed  bool
+}
+
+// WriteHeader writes the status code unless the deadline has passed
+func (w *deadlineWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		if !w.suppressed {
+			w.ResponseWriter.WriteHeader(status)
+		}
+		return
+	}
+	w.wroteHeader = true
+	if errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		w.suppressed = true
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes b unless the response was discarded
+func (w *deadlineWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.suppressed {
+		return 0, context.DeadlineExceeded
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *deadlineWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// respondDeadlineExceeded writes the JSON error response of the API
+func respondDeadlineExceeded(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusGatewayTimeout)
+	fmt.Fprint(w, `{"success":false,"error":{"code":"DEADLINE_EXCEEDED","message":"Request deadline exceeded"}}`+"\n")
+}
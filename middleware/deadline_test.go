@@ -0,0 +1,164 @@
+package middleware_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"otel-go-dbm/dbm"
+	"otel-go-dbm/middleware"
+)
+
+// serveTraced serves a GET request to h inside a span of ctx and returns
+// the response and the ended span
+func serveTraced(t *testing.T, ctx context.Context, h http.Handler) (*httptest.ResponseRecorder, sdktrace.ReadOnlySpan) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer tp.Shutdown(context.Background())
+
+	ctx, span := tp.Tracer("test").Start(ctx, "GET /api/test")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/test", nil).WithContext(ctx))
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	return w, spans[0]
+}
+
+// spanAttr returns the value of the attribute key of span
+func spanAttr(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestDeadlineExceeded(t *testing.T) {
+	var handlerErr error
+	h := middleware.Deadline(&middleware.DeadlineConfig{Timeout: 20 * time.Millisecond})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			handlerErr = r.Context().Err()
+			http.Error(w, "query failed", http.StatusInternalServerError)
+		}))
+
+	w, span := serveTraced(t, context.Background(), h)
+
+	if !errors.Is(handlerErr, context.DeadlineExceeded) {
+		t.Errorf("handler context error = %v, want %v", handlerErr, context.DeadlineExceeded)
+	}
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusGatewayTimeout)
+	}
+	if body := w.Body.String(); !strings.Contains(body, `"code":"DEADLINE_EXCEEDED"`) || strings.Contains(body, "query failed") {
+		t.Errorf("body = %q, want only the DEADLINE_EXCEEDED error", body)
+	}
+	if v, ok := spanAttr(span, middleware.AttrDeadlineExceeded); !ok || !v.AsBool() {
+		t.Errorf("%s = %v, want true", middleware.AttrDeadlineExceeded, v.Emit())
+	}
+	if v, _ := spanAttr(span, middleware.AttrRequestTimeout); v.AsInt64() != 20 {
+		t.Errorf("%s = %v, want 20", middleware.AttrRequestTimeout, v.Emit())
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("span status = %v, want %v", span.Status().Code, codes.Error)
+	}
+}
+
+func TestDeadlineWrittenInTime(t *testing.T) {
+	h := middleware.Deadline(&middleware.DeadlineConfig{Timeout: 20 * time.Millisecond})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+			<-r.Context().Done()
+		}))
+
+	w, span := serveTraced(t, context.Background(), h)
+
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("response = %d %q, want 200 %q", w.Code, w.Body.String(), "ok")
+	}
+	if span.Status().Code == codes.Error {
+		t.Errorf("span status = %v, want no error", span.Status().Code)
+	}
+}
+
+func TestDeadlineClientDisconnected(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var handlerErr error
+	h := middleware.Deadline(&middleware.DeadlineConfig{Timeout: time.Minute})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cancel()
+			<-r.Context().Done()
+			handlerErr = r.Context().Err()
+		}))
+
+	_, span := serveTraced(t, ctx, h)
+
+	if !errors.Is(handlerErr, context.Canceled) {
+		t.Errorf("handler context error = %v, want %v", handlerErr, context.Canceled)
+	}
+	if v, ok := spanAttr(span, middleware.AttrClientDisconnected); !ok || !v.AsBool() {
+		t.Errorf("%s = %v, want true", middleware.AttrClientDisconnected, v.Emit())
+	}
+	if _, ok := spanAttr(span, middleware.AttrDeadlineExceeded); ok {
+		t.Errorf("%s is set on a disconnected request", middleware.AttrDeadlineExceeded)
+	}
+}
+
+// blockingConnector is a driver.Connector whose queries block until their
+// context is done
+type blockingConnector struct{}
+
+func (blockingConnector) Connect(context.Context) (driver.Conn, error) { return blockingConn{}, nil }
+func (blockingConnector) Driver() driver.Driver                        { return nil }
+
+type blockingConn struct{}
+
+func (blockingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (blockingConn) Close() error                        { return nil }
+func (blockingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (blockingConn) QueryContext(ctx context.Context, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestDeadlineCancelsQuery(t *testing.T) {
+	db := sql.OpenDB(dbm.NewConnector(blockingConnector{}, dbm.NewCommenter(nil)))
+	defer db.Close()
+
+	var queryErr error
+	h := middleware.Deadline(&middleware.DeadlineConfig{Timeout: 20 * time.Millisecond})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, queryErr = db.QueryContext(r.Context(), "SELECT 1")
+		}))
+
+	start := time.Now()
+	w, _ := serveTraced(t, context.Background(), h)
+
+	if !errors.Is(queryErr, context.DeadlineExceeded) {
+		t.Errorf("QueryContext error = %v, want %v", queryErr, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("query ran for %v after the deadline", elapsed)
+	}
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusGatewayTimeout)
+	}
+}
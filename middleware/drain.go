@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDrainRetryAfter is the Retry-After of requests rejected while
+// draining by default
+const DefaultDrainRetryAfter = 30 * time.Second
+
+// DrainConfig holds configuration for Drainer
+type DrainConfig struct {
+	// RetryAfter is sent in the Retry-After header of rejected requests.
+	// Defaults to DefaultDrainRetryAfter.
+	RetryAfter time.Duration
+
+	// Respond writes the response of a request rejected while draining,
+	// after the Retry-After header is set. Defaults to a JSON 503 with the
+	// code DRAINING.
+	Respond func(w http.ResponseWriter, r *http.Request)
+}
+
+// Drainer takes the service out of rotation for deploys and maintenance.
+// While draining, the middleware of the Drainer rejects new requests with
+// a 503 and a Retry-After header, requests already in flight finish, and
+// readiness checks should report Draining so the load balancer stops
+// sending requests. http.Server.Shutdown then waits for the requests in
+// flight.
+type Drainer struct {
+	config DrainConfig
+
+	mu       sync.Mutex
+	since    time.Time // zero when not draining
+	inFlight atomic.Int64
+}
+
+// NewDrainer creates a new Drainer, not draining
+func NewDrainer(config *DrainConfig) *Drainer {
+	cfg := DrainConfig{
+		RetryAfter: DefaultDrainRetryAfter,
+		Respond:    respondDraining,
+	}
+	if config != nil {
+		if config.RetryAfter > 0 {
+			cfg.RetryAfter = config.RetryAfter
+		}
+		if config.Respond != nil {
+			cfg.Respond = config.Respond
+		}
+	}
+	return &Drainer{config: cfg}
+}
+
+// Drain starts draining. It returns false if the Drainer was already
+// draining.
+func (d *Drainer) Drain() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.since.IsZero() {
+		return false
+	}
+	d.since = time.Now()
+	return true
+}
+
+// Resume stops draining. It returns false if the Drainer was not draining.
+func (d *Drainer) Resume() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.since.IsZero() {
+		return false
+	}
+	d.since = time.Time{}
+	return true
+}
+
+// Draining reports whether the Drainer is draining and since when
+func (d *Drainer) Draining() (bool, time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return !d.since.IsZero(), d.since
+}
+
+// InFlight returns the number of requests being handled by the middleware
+func (d *Drainer) InFlight() int64 {
+	return d.inFlight.Load()
+}
+
+// Middleware returns a Middleware that rejects requests while draining
+// and counts the requests in flight
+func (d *Drainer) Middleware() Middleware {
+	retryAfter := strconv.Itoa(int(d.config.RetryAfter.Seconds()))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if draining, _ := d.Draining(); draining {
+				w.Header().Set("Retry-After", retryAfter)
+				w.Header().Set("Connection", "close")
+				d.config.Respond(w, r)
+				return
+			}
+
+			d.inFlight.Add(1)
+			defer d.inFlight.Add(-1)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// respondDraining writes the JSON error response of the API
+func respondDraining(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprint(w, `{"success":false,"error":{"code":"DRAINING","message":"Service is draining"}}`+"\n")
+}
@@ -0,0 +1,267 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+)
+
+const instrumentationName = "otel-go-dbm/middleware"
+
+// DefaultRateLimitMaxClients is the number of clients tracked by default
+const DefaultRateLimitMaxClients = 10_000
+
+// DefaultRateLimitIdleTimeout is the idle time after which a client is
+// forgotten by default
+const DefaultRateLimitIdleTimeout = 10 * time.Minute
+
+// Attributes set on the span of throttled requests
+const (
+	AttrRateLimited    = attribute.Key("http.rate_limited")
+	AttrRateLimitScope = attribute.Key("rate_limit.scope")
+	// AttrRateLimitRetryAfter is the wait in seconds until a token is free
+	AttrRateLimitRetryAfter = attribute.Key("rate_limit.retry_after")
+)
+
+// Scopes of a rate limit
+const (
+	RateLimitScopeClient = "client"
+	RateLimitScopeGlobal = "global"
+)
+
+// RateLimitConfig holds configuration for RateLimiter. A rate of 0
+// disables the limit.
+type RateLimitConfig struct {
+	// Rate is the number of requests per second allowed for each client
+	Rate float64
+
+	// Burst is the number of requests a client may make at once. Defaults
+	// to Rate rounded up, and at least 1.
+	Burst int
+
+	// GlobalRate is the number of requests per second allowed for all
+	// clients together
+	GlobalRate float64
+
+	// GlobalBurst is the number of requests all clients may make at once.
+	// Defaults to GlobalRate rounded up, and at least 1.
+	GlobalBurst int
+
+	// Key identifies the client of a request. Defaults to the IP of the
+	// remote address.
+	Key func(r *http.Request) string
+
+	// MaxClients is the number of clients tracked; beyond it, clients are
+	// forgotten, starting with the idle ones. Defaults to
+	// DefaultRateLimitMaxClients.
+	MaxClients int
+
+	// IdleTimeout forgets clients without requests for this long. Defaults
+	// to DefaultRateLimitIdleTimeout.
+	IdleTimeout time.Duration
+
+	// Respond writes the response of a throttled request, after the
+	// Retry-After header is set. Defaults to a JSON 429 with the code
+	// RATE_LIMITED.
+	Respond func(w http.ResponseWriter, r *http.Request)
+}
+
+// RateLimiter limits the request rate with token buckets, one per client
+// and one for all clients, so a single client cannot starve the others and
+// all of them together cannot overload the database. Throttled requests are
+// answered with a 429 and a Retry-After header, marked on their span and
+// counted in the http.server.rate_limited metric.
+type RateLimiter struct {
+	config  RateLimitConfig
+	global  *rate.Limiter
+	limited metric.Int64Counter
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+// clientLimiter is the bucket of one client
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewRateLimiter creates a new RateLimiter
+func NewRateLimiter(config *RateLimitConfig) *RateLimiter {
+	cfg := RateLimitConfig{
+		Key:         remoteIP,
+		MaxClients:  DefaultRateLimitMaxClients,
+		IdleTimeout: DefaultRateLimitIdleTimeout,
+		Respond:     respondRateLimited,
+	}
+	if config != nil {
+		cfg.Rate = config.Rate
+		cfg.Burst = config.Burst
+		cfg.GlobalRate = config.GlobalRate
+		cfg.GlobalBurst = config.GlobalBurst
+		if config.Key != nil {
+			cfg.Key = config.Key
+		}
+		if config.MaxClients > 0 {
+			cfg.MaxClients = config.MaxClients
+		}
+		if config.IdleTimeout > 0 {
+			cfg.IdleTimeout = config.IdleTimeout
+		}
+		if config.Respond != nil {
+			cfg.Respond = config.Respond
+		}
+	}
+	cfg.Burst = burst(cfg.Rate, cfg.Burst)
+	cfg.GlobalBurst = burst(cfg.GlobalRate, cfg.GlobalBurst)
+
+	l := &RateLimiter{
+		config:    cfg,
+		clients:   make(map[string]*clientLimiter),
+		lastSweep: time.Now(),
+	}
+	if cfg.GlobalRate > 0 {
+		l.global = rate.NewLimiter(rate.Limit(cfg.GlobalRate), cfg.GlobalBurst)
+	}
+
+	var err error
+	meter := otel.GetMeterProvider().Meter(instrumentationName)
+	if l.limited, err = meter.Int64Counter("http.server.rate_limited",
+		metric.WithDescription("Number of HTTP requests rejected by the rate limit"),
+		metric.WithUnit("{request}"),
+	); err != nil {
+		otel.Handle(err)
+	}
+	return l
+}
+
+// Middleware returns a Middleware limiting the requests of route with the
+// buckets of l. Routes sharing a RateLimiter share its limits; route only
+// labels the metric.
+func (l *RateLimiter) Middleware(route string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scope, retryAfter := l.reserve(r)
+			if scope == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			trace.SpanFromContext(ctx).SetAttributes(
+				AttrRateLimited.Bool(true),
+				AttrRateLimitScope.String(scope),
+				AttrRateLimitRetryAfter.Float64(retryAfter.Seconds()),
+			)
+			if l.limited != nil {
+				l.limited.Add(ctx, 1, metric.WithAttributes(
+					semconv.HTTPRoute(route),
+					AttrRateLimitScope.String(scope),
+				))
+			}
+
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			l.config.Respond(w, r)
+		})
+	}
+}
+
+// reserve takes a token from the bucket of the client of r and from the
+// global bucket. If either is empty, no token is taken, and the scope of
+// the empty bucket and the wait until it has a token are returned.
+func (l *RateLimiter) reserve(r *http.Request) (scope string, retryAfter time.Duration) {
+	now := time.Now()
+	var client *rate.Reservation
+	if l.config.Rate > 0 {
+		client = l.client(l.config.Key(r), now).ReserveN(now, 1)
+		if delay := client.DelayFrom(now); delay > 0 {
+			client.CancelAt(now)
+			return RateLimitScopeClient, delay
+		}
+	}
+	if l.global != nil {
+		global := l.global.ReserveN(now, 1)
+		if delay := global.DelayFrom(now); delay > 0 {
+			global.CancelAt(now)
+			if client != nil {
+				client.CancelAt(now)
+			}
+			return RateLimitScopeGlobal, delay
+		}
+	}
+	return "", 0
+}
+
+// client returns the bucket of the client key, creating it if needed
+func (l *RateLimiter) client(key string, now time.Time) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if c, ok := l.clients[key]; ok {
+		c.lastSeen = now
+		return c.limiter
+	}
+	if now.Sub(l.lastSweep) >= l.config.IdleTimeout || len(l.clients) >= l.config.MaxClients {
+		l.sweep(now)
+	}
+	// Still full: forget any client, which starts over with a full bucket
+	for k := range l.clients {
+		if len(l.clients) < l.config.MaxClients {
+			break
+		}
+		delete(l.clients, k)
+	}
+
+	c := &clientLimiter{
+		limiter:  rate.NewLimiter(rate.Limit(l.config.Rate), l.config.Burst),
+		lastSeen: now,
+	}
+	l.clients[key] = c
+	return c.limiter
+}
+
+// sweep forgets the clients idle for IdleTimeout
+func (l *RateLimiter) sweep(now time.Time) {
+	for k, c := range l.clients {
+		if now.Sub(c.lastSeen) >= l.config.IdleTimeout {
+			delete(l.clients, k)
+		}
+	}
+	l.lastSweep = now
+}
+
+// burst returns b, or r rounded up (at least 1) if b is not set
+func burst(r float64, b int) int {
+	if b > 0 {
+		return b
+	}
+	return max(int(math.Ceil(r)), 1)
+}
+
+// remoteIP returns the IP of the remote address of r
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// respondRateLimited writes the JSON error response of the API
+func respondRateLimited(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	fmt.Fprint(w, `{"success":false,"error":{"code":"RATE_LIMITED","message":"Too many requests"}}`+"\n")
+}
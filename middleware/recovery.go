@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	otellog "otel-go-dbm/log"
+)
+
+// RecoveryConfig holds configuration for Recovery
+type RecoveryConfig struct {
+	// Logger writes the recovered panics. Defaults to slog.Default() at the
+	// time of the panic.
+	Logger *slog.Logger
+
+	// Respond writes the response of a recovered panic when the handler
+	// has not written one yet. Defaults to a JSON 500 with the code
+	// INTERNAL_ERROR.
+	Respond func(w http.ResponseWriter, r *http.Request)
+}
+
+// Recovery returns a Middleware that recovers from panics of the next
+// handlers. The panic is recorded as an exception with its stack trace on
+// the span of the request, which is marked as an error, logged at error
+// level with the trace fields and the stack trace, and answered with a 500
+// instead of the connection being dropped. http.ErrAbortHandler is passed
+// through, as net/http uses it to abort a response on purpose.
+//
+// Add it inside the tracing middleware so the span of the request is in
+// the context.
+func Recovery(config *RecoveryConfig) Middleware {
+	cfg := RecoveryConfig{Respond: respondInternalError}
+	if config != nil {
+		cfg.Logger = config.Logger
+		if config.Respond != nil {
+			cfg.Respond = config.Respond
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := &headerWriter{ResponseWriter: w}
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+
+				// Called from the deferred function, the stack includes
+				// the panicking frames
+				stack := otellog.Stack(0)
+				err, ok := p.(error)
+				if !ok {
+					err = fmt.Errorf("%v", p)
+				}
+
+				ctx := r.Context()
+				span := trace.SpanFromContext(ctx)
+				span.RecordError(err, trace.WithAttributes(
+					semconv.ExceptionStacktrace(stack),
+					semconv.ExceptionEscaped(false),
+				))
+				span.SetStatus(codes.Error, "panic: "+err.Error())
+
+				logger := cfg.Logger
+				if logger == nil {
+					logger = slog.Default()
+				}
+				logger.LogAttrs(ctx, slog.LevelError, "Recovered from panic",
+					slog.Any("panic", err),
+					slog.String("http.request.method", r.Method),
+					slog.String("url.path", r.URL.Path),
+					slog.String(otellog.StackKey, stack),
+				)
+
+				if !rw.wroteHeader {
+					cfg.Respond(rw, r)
+				}
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// respondInternalError writes the JSON error response of the API
+func respondInternalError(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprint(w, `{"success":false,"error":{"code":"INTERNAL_ERROR","message":"Internal server error"}}`+"\n")
+}
+
+// headerWriter records whether the response header was written
+type headerWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+// WriteHeader marks the header as written
+func (w *headerWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write marks the header as written
+func (w *headerWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *headerWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
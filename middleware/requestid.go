@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultRequestIDHeader is the header of the request ID
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// RequestIDBaggageKey is the baggage member of the request ID. Add it to
+// log.TraceHandlerConfig.BaggageKeys to log the request ID.
+const RequestIDBaggageKey = "request_id"
+
+// AttrRequestID is the span attribute of the request ID
+const AttrRequestID = attribute.Key("http.request.id")
+
+// maxRequestIDLength bounds accepted request IDs
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestIDConfig holds configuration for RequestID
+type RequestIDConfig struct {
+	// Header carries the request ID in requests and responses. Defaults to
+	// DefaultRequestIDHeader.
+	Header string
+
+	// IgnoreIncoming always generates a new ID instead of accepting the one
+	// of the request, e.g. when clients are not trusted
+	IgnoreIncoming bool
+
+	// Generate returns a new request ID. Defaults to 32 random hex digits.
+	Generate func() string
+}
+
+// RequestID returns a Middleware that gives each request an ID: the one
+// of the request header when it is valid, and a generated one otherwise.
+// The ID is returned in the response header, set as http.request.id on
+// the span of the request and as the request_id member of the baggage, so
+// it reaches the logs and downstream services, and stored in the context
+// (see RequestIDFromContext). Support tickets quoting the ID can then be
+// matched to traces even when proxies strip the trace headers.
+//
+// Add it inside the tracing middleware so the span of the request is in
+// the context.
+func RequestID(config *RequestIDConfig) Middleware {
+	cfg := RequestIDConfig{
+		Header:   DefaultRequestIDHeader,
+		Generate: newRequestID,
+	}
+	if config != nil {
+		if config.Header != "" {
+			cfg.Header = config.Header
+		}
+		cfg.IgnoreIncoming = config.IgnoreIncoming
+		if config.Generate != nil {
+			cfg.Generate = config.Generate
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(cfg.Header)
+			if cfg.IgnoreIncoming || !validRequestID(id) {
+				id = cfg.Generate()
+			}
+			w.Header().Set(cfg.Header, id)
+
+			ctx := r.Context()
+			trace.SpanFromContext(ctx).SetAttributes(AttrRequestID.String(id))
+			// The member of the caller's baggage, if any, is replaced
+			if m, err := baggage.NewMemberRaw(RequestIDBaggageKey, id); err == nil {
+				if b, err := baggage.FromContext(ctx).SetMember(m); err == nil {
+					ctx = baggage.ContextWithBaggage(ctx, b)
+				}
+			}
+			ctx = context.WithValue(ctx, requestIDKey{}, id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequestIDFromContext returns the request ID stored in ctx by RequestID,
+// or "" if none is set
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID returns 32 random hex digits
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// validRequestID reports whether id is short and only has characters safe
+// to log and echo in a header: letters, digits and -_.:
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
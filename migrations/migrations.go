@@ -0,0 +1,321 @@
+// Package migrations embeds the SQL schema of the sample application and
+// applies it with versioned up and down migrations.
+//
+// Migrations live in <driver>/<version>_<name>.up.sql and .down.sql, one
+// directory per DB_DRIVER. Statements are separated by a line ending in a
+// semicolon. Applied versions are recorded in the schema_migrations table.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"otel-go-dbm/dbm"
+)
+
+const instrumentationName = "otel-go-dbm/migrations"
+
+//go:embed postgres/*.sql mysql/*.sql sqlserver/*.sql
+var files embed.FS
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a migration has been applied
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies the embedded migrations for a driver
+type Migrator struct {
+	db         *sql.DB
+	driverName string
+	migrations []Migration
+	tracer     trace.Tracer
+}
+
+// New creates a Migrator for db. driverName is the DB_DRIVER value:
+// postgres, mysql or sqlserver.
+func New(db *sql.DB, driverName string) (*Migrator, error) {
+	migrations, err := load(driverName)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		driverName: driverName,
+		migrations: migrations,
+		tracer:     otel.GetTracerProvider().Tracer(instrumentationName),
+	}, nil
+}
+
+// Up applies all pending migrations in version order and returns them
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		err := m.run(ctx, "migrate.up", mig, mig.Up, func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, m.rebind("INSERT INTO schema_migrations (version, name) VALUES (?, ?)"), mig.Version, mig.Name)
+			return err
+		})
+		if err != nil {
+			return done, err
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Down reverts the most recently applied migration and returns it.
+// It returns nil when no migration is applied.
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		err := m.run(ctx, "migrate.down", mig, mig.Down, func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, m.rebind("DELETE FROM schema_migrations WHERE version = ?"), mig.Version)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &mig, nil
+	}
+	return nil, nil
+}
+
+// Status returns every embedded migration with the time it was applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := Status{Migration: mig}
+		if at, ok := applied[mig.Version]; ok {
+			s.AppliedAt = &at
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// Pending returns the embedded migrations that are not applied yet. Unlike
+// Up and Status it does not create the schema_migrations table, so it only
+// reads and fails if the table does not exist, e.g. for readiness checks.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.readApplied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// run executes script and record in one transaction inside a span named name
+func (m *Migrator) run(ctx context.Context, name string, mig Migration, script string, record func(context.Context, *sql.Tx) error) error {
+	ctx, span := m.tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.Int64("db.migration.version", mig.Version),
+		attribute.String("db.migration.name", mig.Name),
+	))
+	defer span.End()
+
+	err := dbm.WithTx(ctx, m.db, func(ctx context.Context, tx *sql.Tx) error {
+		for _, stmt := range splitStatements(script) {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return record(ctx, tx)
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
+
+// applied creates the schema_migrations table if needed and returns the
+// applied versions with the time they were applied
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	if _, err := m.db.ExecContext(ctx, m.createTable()); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return m.readApplied(ctx)
+}
+
+// readApplied returns the applied versions with the time they were applied
+func (m *Migrator) readApplied(ctx context.Context) (map[int64]time.Time, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			version   int64
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// createTable returns the dialect's DDL for the schema_migrations table
+func (m *Migrator) createTable() string {
+	switch m.driverName {
+	case "mysql":
+		return `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+		)`
+	case "sqlserver":
+		return `IF OBJECT_ID('schema_migrations', 'U') IS NULL
+		CREATE TABLE schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name NVARCHAR(255) NOT NULL,
+			applied_at DATETIME2 NOT NULL DEFAULT SYSUTCDATETIME()
+		)`
+	}
+	return `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`
+}
+
+// rebind rewrites ? placeholders for the driver
+func (m *Migrator) rebind(query string) string {
+	return sqlx.Rebind(sqlx.BindType(m.driverName), query)
+}
+
+// load reads the embedded migrations for driverName sorted by version
+func load(driverName string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, driverName)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for driver %q", driverName)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		base, direction, ok := cutDirection(e.Name())
+		if !ok {
+			continue
+		}
+		v, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name: %s", e.Name())
+		}
+		version, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version: %s", e.Name())
+		}
+
+		b, err := files.ReadFile(path.Join(driverName, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: name}
+			byVersion[version] = mig
+		}
+		if direction == "up" {
+			mig.Up = string(b)
+		} else {
+			mig.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up script", mig.Version)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// cutDirection splits "0001_name.up.sql" into "0001_name" and "up"
+func cutDirection(file string) (string, string, bool) {
+	if base, ok := strings.CutSuffix(file, ".up.sql"); ok {
+		return base, "up", true
+	}
+	if base, ok := strings.CutSuffix(file, ".down.sql"); ok {
+		return base, "down", true
+	}
+	return "", "", false
+}
+
+// splitStatements splits script into statements at lines ending in a semicolon
+func splitStatements(script string) []string {
+	var (
+		stmts   []string
+		current strings.Builder
+	)
+	for _, line := range strings.Split(script, "\n") {
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			if stmt := strings.TrimSuffix(strings.TrimSpace(current.String()), ";"); stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+			current.Reset()
+		}
+	}
+	if stmt := strings.TrimSpace(current.String()); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
@@ -0,0 +1,37 @@
+IF OBJECT_ID('users', 'U') IS NULL
+CREATE TABLE users (
+    id INT IDENTITY(1, 1) PRIMARY KEY,
+    name NVARCHAR(255) NOT NULL,
+    email NVARCHAR(255) NOT NULL UNIQUE,
+    created_at DATETIME2 NOT NULL DEFAULT SYSUTCDATETIME()
+);
+
+IF OBJECT_ID('products', 'U') IS NULL
+CREATE TABLE products (
+    id INT IDENTITY(1, 1) PRIMARY KEY,
+    name NVARCHAR(255) NOT NULL,
+    category NVARCHAR(100) NOT NULL DEFAULT '',
+    price DECIMAL(10, 2) NOT NULL,
+    created_at DATETIME2 NOT NULL DEFAULT SYSUTCDATETIME()
+);
+
+IF OBJECT_ID('orders', 'U') IS NULL
+CREATE TABLE orders (
+    id INT IDENTITY(1, 1) PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id),
+    status NVARCHAR(50) NOT NULL DEFAULT 'pending',
+    order_date DATETIME2 NOT NULL DEFAULT SYSUTCDATETIME(),
+    total_amount DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    INDEX idx_orders_user_id (user_id)
+);
+
+IF OBJECT_ID('order_items', 'U') IS NULL
+CREATE TABLE order_items (
+    id INT IDENTITY(1, 1) PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products (id),
+    quantity INT NOT NULL,
+    unit_price DECIMAL(10, 2) NOT NULL,
+    INDEX idx_order_items_order_id (order_id),
+    INDEX idx_order_items_product_id (product_id)
+);
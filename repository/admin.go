@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"otel-go-dbm/dbm"
+	"otel-go-dbm/dbm/sqlcomment"
+)
+
+// ErrUnsupported is returned when the database driver does not support the
+// requested diagnostics
+var ErrUnsupported = errors.New("not supported by this database")
+
+// ErrUnavailable is returned when the diagnostics need a database extension
+// that is not installed
+var ErrUnavailable = errors.New("not available in this database")
+
+// SQLSTATE of a missing table, e.g. pg_stat_statements before CREATE EXTENSION
+const sqlStateUndefinedTable = "42P01"
+
+// Orders of TopStatements
+const (
+	OrderByTotalTime = "total_time"
+	OrderByCalls     = "calls"
+	OrderByMeanTime  = "mean_time"
+)
+
+// AdminRepo reads database diagnostics for the admin endpoints
+type AdminRepo interface {
+	// TopStatements returns the limit statements of the current database with
+	// the highest orderBy (OrderByTotalTime, OrderByCalls or OrderByMeanTime)
+	// from pg_stat_statements. It returns ErrUnsupported on other databases
+	// than PostgreSQL and ErrUnavailable when the extension is not installed.
+	TopStatements(ctx context.Context, orderBy string, limit int) ([]StatementStats, error)
+
+	// LockWaits returns the sessions of the current database waiting for a
+	// lock together with the sessions blocking them. It returns
+	// ErrUnsupported on other databases than PostgreSQL.
+	LockWaits(ctx context.Context) ([]LockWait, error)
+}
+
+var _ AdminRepo = (*Store)(nil)
+
+// StatementStats is one normalized statement from pg_stat_statements
+type StatementStats struct {
+	QueryID        int64   `json:"query_id"`
+	Query          string  `json:"query"`
+	Calls          int64   `json:"calls"`
+	TotalTimeMs    float64 `json:"total_time_ms"`
+	MeanTimeMs     float64 `json:"mean_time_ms"`
+	Rows           int64   `json:"rows"`
+	SharedBlksHit  int64   `json:"shared_blks_hit"`
+	SharedBlksRead int64   `json:"shared_blks_read"`
+}
+
+// TopStatements returns the statements with the highest orderBy from pg_stat_statements
+func (s *Store) TopStatements(ctx context.Context, orderBy string, limit int) ([]StatementStats, error) {
+	ctx, span := s.startSpan(ctx, "AdminRepo.TopStatements",
+		attribute.String("db.statements.order_by", orderBy),
+		attribute.Int("db.statements.limit", limit),
+	)
+	defer span.End()
+
+	if s.config.DriverName != "postgres" {
+		return nil, ErrUnsupported
+	}
+
+	// PostgreSQL 13 renamed total_time and mean_time to total_exec_time and mean_exec_time
+	var version int
+	if err := s.config.DB.QueryRowContext(ctx, "SELECT current_setting('server_version_num')::int").Scan(&version); err != nil {
+		return nil, recordError(span, fmt.Errorf("failed to read server version: %w", err))
+	}
+	totalTime, meanTime := "total_exec_time", "mean_exec_time"
+	if version < 130000 {
+		totalTime, meanTime = "total_time", "mean_time"
+	}
+
+	var order string
+	switch orderBy {
+	case OrderByCalls:
+		order = "calls"
+	case OrderByMeanTime:
+		order = meanTime
+	default:
+		order = totalTime
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			COALESCE(queryid, 0),
+			query,
+			calls,
+			%[1]s,
+			%[2]s,
+			rows,
+			shared_blks_hit,
+			shared_blks_read
+		FROM pg_stat_statements
+		WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+		ORDER BY %[3]s DESC
+		LIMIT $1
+	`, totalTime, meanTime, order)
+
+	stats, err := queryAll(ctx, s, s.config.DB, func(rows *sql.Rows) (StatementStats, error) {
+		var stat StatementStats
+		err := rows.Scan(
+			&stat.QueryID,
+			&stat.Query,
+			&stat.Calls,
+			&stat.TotalTimeMs,
+			&stat.MeanTimeMs,
+			&stat.Rows,
+			&stat.SharedBlksHit,
+			&stat.SharedBlksRead,
+		)
+		return stat, err
+	}, query, limit)
+	if err != nil {
+		var pgErr interface{ SQLState() string }
+		if errors.As(err, &pgErr) && pgErr.SQLState() == sqlStateUndefinedTable {
+			return nil, recordError(span, fmt.Errorf("pg_stat_statements: %w", ErrUnavailable))
+		}
+		return nil, recordError(span, fmt.Errorf("failed to query pg_stat_statements: %w", err))
+	}
+	return stats, nil
+}
+
+// LockWait is a session waiting for a lock held by another session
+type LockWait struct {
+	Blocked  Session `json:"blocked"`
+	Blocking Session `json:"blocking"`
+
+	// The lock the blocked session waits for
+	LockType string `json:"lock_type"`
+	LockMode string `json:"lock_mode"`
+	Relation string `json:"relation,omitempty"`
+}
+
+// Session is a backend from pg_stat_activity. TraceID, SpanID and Route are
+// read from the SQL comment of its query, linking the session to the trace
+// that issued the query.
+type Session struct {
+	PID         int     `json:"pid"`
+	User        string  `json:"user"`
+	Application string  `json:"application"`
+	State       string  `json:"state"`
+	Query       string  `json:"query"`
+	DurationMs  float64 `json:"duration_ms"`
+	TraceID     string  `json:"trace_id,omitempty"`
+	SpanID      string  `json:"span_id,omitempty"`
+	Route       string  `json:"route,omitempty"`
+}
+
+// LockWaits returns the blocked sessions and the sessions blocking them
+func (s *Store) LockWaits(ctx context.Context) ([]LockWait, error) {
+	ctx, span := s.startSpan(ctx, "AdminRepo.LockWaits")
+	defer span.End()
+
+	if s.config.DriverName != "postgres" {
+		return nil, ErrUnsupported
+	}
+
+	// The blocked duration counts from the start of the waiting query, the
+	// blocking one from the start of the transaction holding the lock
+	query := `
+		SELECT
+			blocked.pid,
+			COALESCE(blocked.usename, ''),
+			blocked.application_name,
+			COALESCE(blocked.state, ''),
+			blocked.query,
+			COALESCE(EXTRACT(EPOCH FROM now() - blocked.query_start) * 1000, 0),
+			blocking.pid,
+			COALESCE(blocking.usename, ''),
+			blocking.application_name,
+			COALESCE(blocking.state, ''),
+			blocking.query,
+			COALESCE(EXTRACT(EPOCH FROM now() - blocking.xact_start) * 1000, 0),
+			COALESCE(waiting.locktype, ''),
+			COALESCE(waiting.mode, ''),
+			COALESCE(waiting.relation::regclass::text, '')
+		FROM pg_stat_activity blocked
+		CROSS JOIN LATERAL unnest(pg_blocking_pids(blocked.pid)) AS blocker(pid)
+		JOIN pg_stat_activity blocking ON blocking.pid = blocker.pid
+		LEFT JOIN LATERAL (
+			SELECT locktype, mode, relation
+			FROM pg_locks
+			WHERE pg_locks.pid = blocked.pid AND NOT granted
+			LIMIT 1
+		) waiting ON true
+		WHERE blocked.datname = current_database()
+		ORDER BY blocked.query_start
+	`
+
+	waits, err := queryAll(ctx, s, s.config.DB, func(rows *sql.Rows) (LockWait, error) {
+		var w LockWait
+		err := rows.Scan(
+			&w.Blocked.PID,
+			&w.Blocked.User,
+			&w.Blocked.Application,
+			&w.Blocked.State,
+			&w.Blocked.Query,
+			&w.Blocked.DurationMs,
+			&w.Blocking.PID,
+			&w.Blocking.User,
+			&w.Blocking.Application,
+			&w.Blocking.State,
+			&w.Blocking.Query,
+			&w.Blocking.DurationMs,
+			&w.LockType,
+			&w.LockMode,
+			&w.Relation,
+		)
+		w.Blocked.parseComment()
+		w.Blocking.parseComment()
+		return w, err
+	}, query)
+	if err != nil {
+		return nil, recordError(span, fmt.Errorf("failed to query lock waits: %w", err))
+	}
+	return waits, nil
+}
+
+// parseComment fills TraceID, SpanID and Route from the SQL comment of the
+// query. Queries without a valid comment leave them empty.
+func (s *Session) parseComment() {
+	tags, err := sqlcomment.Parse(s.Query)
+	if err != nil {
+		return
+	}
+	s.Route = tags[dbm.KeyRoute]
+	if tp, err := sqlcomment.ParseTraceparent(tags[dbm.KeyTraceparent]); err == nil {
+		s.TraceID = tp.TraceID.String()
+		s.SpanID = tp.SpanID.String()
+	}
+}
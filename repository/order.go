@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// OrderDetail is one item of an order joined with its user and product
+type OrderDetail struct {
+	OrderID      uint      `json:"order_id"`
+	OrderStatus  string    `json:"order_status"`
+	OrderDate    time.Time `json:"order_date"`
+	TotalAmount  float64   `json:"total_amount"`
+	UserID       uint      `json:"user_id"`
+	UserName     string    `json:"user_name"`
+	UserEmail    string    `json:"user_email"`
+	ItemID       uint      `json:"item_id"`
+	ProductID    uint      `json:"product_id"`
+	ProductName  string    `json:"product_name"`
+	ProductPrice float64   `json:"product_price"`
+	Quantity     int       `json:"quantity"`
+	ItemTotal    float64   `json:"item_total"`
+}
+
+// OrderTotals is the number and total amount of the orders in one status
+type OrderTotals struct {
+	Status  string  `json:"status"`
+	Count   int64   `json:"count"`
+	Revenue float64 `json:"revenue"`
+}
+
+// Details returns one row per item of the order, or ErrNotFound.
+// It reads from the primary so a just-created order is visible.
+func (s *Store) Details(ctx context.Context, orderID uint64) ([]OrderDetail, error) {
+	ctx, span := s.startSpan(ctx, "OrderRepo.Details", attribute.Int64("order_id", int64(orderID)))
+	defer span.End()
+
+	query := s.rebind(`
+		SELECT
+			orders.id as order_id,
+			orders.status as order_status,
+			orders.order_date,
+			orders.total_amount,
+			users.id as user_id,
+			users.name as user_name,
+			users.email as user_email,
+			order_items.id as item_id,
+			products.id as product_id,
+			products.name as product_name,
+			order_items.unit_price as product_price,
+			order_items.quantity,
+			(order_items.unit_price * order_items.quantity) as item_total
+		FROM orders
+		INNER JOIN users ON users.id = orders.user_id
+		LEFT JOIN order_items ON order_items.order_id = orders.id
+		LEFT JOIN products ON products.id = order_items.product_id
+		WHERE orders.id = ?
+	`)
+
+	details, err := queryAll(ctx, s, s.config.DB, func(rows *sql.Rows) (OrderDetail, error) {
+		var detail OrderDetail
+		err := rows.Scan(
+			&detail.OrderID,
+			&detail.OrderStatus,
+			&detail.OrderDate,
+			&detail.TotalAmount,
+			&detail.UserID,
+			&detail.UserName,
+			&detail.UserEmail,
+			&detail.ItemID,
+			&detail.ProductID,
+			&detail.ProductName,
+			&detail.ProductPrice,
+			&detail.Quantity,
+			&detail.ItemTotal,
+		)
+		return detail, err
+	}, query, orderID)
+	if err != nil {
+		return nil, recordError(span, fmt.Errorf("failed to query order %d: %w", orderID, err))
+	}
+	span.SetAttributes(attribute.Int("details.count", len(details)))
+	if len(details) == 0 {
+		return nil, ErrNotFound
+	}
+	return details, nil
+}
+
+// Totals returns the number and total amount of the orders per status
+func (s *Store) Totals(ctx context.Context) ([]OrderTotals, error) {
+	ctx, span := s.startSpan(ctx, "OrderRepo.Totals")
+	defer span.End()
+
+	query := `
+		SELECT
+			status,
+			COUNT(*) as order_count,
+			COALESCE(SUM(total_amount), 0) as revenue
+		FROM orders
+		GROUP BY status
+	`
+
+	totals, err := queryAll(ctx, s, s.readDB(), func(rows *sql.Rows) (OrderTotals, error) {
+		var total OrderTotals
+		err := rows.Scan(&total.Status, &total.Count, &total.Revenue)
+		return total, err
+	}, query)
+	if err != nil {
+		return nil, recordError(span, fmt.Errorf("failed to query order totals: %w", err))
+	}
+	return totals, nil
+}
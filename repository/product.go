@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ProductSalesStats is the sales summary of one product
+type ProductSalesStats struct {
+	ProductID    uint    `json:"product_id"`
+	ProductName  string  `json:"product_name"`
+	Category     string  `json:"category"`
+	TotalSold    int64   `json:"total_sold"`
+	TotalRevenue float64 `json:"total_revenue"`
+	OrderCount   int64   `json:"order_count"`
+	AvgPrice     float64 `json:"avg_price"`
+}
+
+// CategoryStats is the sales summary over all products
+type CategoryStats struct {
+	ProductCount int64   `json:"product_count"`
+	TotalSold    int64   `json:"total_sold"`
+	TotalRevenue float64 `json:"total_revenue"`
+	AvgPrice     float64 `json:"avg_price"`
+}
+
+// SalesStats returns the 50 products with the highest revenue
+func (s *Store) SalesStats(ctx context.Context) ([]ProductSalesStats, error) {
+	ctx, span := s.startSpan(ctx, "ProductRepo.SalesStats")
+	defer span.End()
+
+	query := `
+		SELECT
+			products.id as product_id,
+			products.name as product_name,
+			'' as category,
+			COALESCE(SUM(order_items.quantity), 0) as total_sold,
+			COALESCE(SUM(order_items.quantity * order_items.unit_price), 0) as total_revenue,
+			COUNT(DISTINCT order_items.order_id) as order_count,
+			COALESCE(AVG(order_items.unit_price), products.price) as avg_price
+		FROM products
+		LEFT JOIN order_items ON order_items.product_id = products.id
+		LEFT JOIN orders ON orders.id = order_items.order_id AND orders.status = 'completed'
+		GROUP BY products.id, products.name, products.price
+		ORDER BY total_revenue DESC
+		LIMIT 50
+	`
+
+	stats, err := queryAll(ctx, s, s.readDB(), func(rows *sql.Rows) (ProductSalesStats, error) {
+		var stat ProductSalesStats
+		err := rows.Scan(
+			&stat.ProductID,
+			&stat.ProductName,
+			&stat.Category,
+			&stat.TotalSold,
+			&stat.TotalRevenue,
+			&stat.OrderCount,
+			&stat.AvgPrice,
+		)
+		return stat, err
+	}, query)
+	if err != nil {
+		return nil, recordError(span, fmt.Errorf("failed to query product sales stats: %w", err))
+	}
+	return stats, nil
+}
+
+// CategoryStats returns the sales totals over all products
+func (s *Store) CategoryStats(ctx context.Context) (CategoryStats, error) {
+	ctx, span := s.startSpan(ctx, "ProductRepo.CategoryStats")
+	defer span.End()
+
+	query := `
+		SELECT
+			COUNT(DISTINCT products.id) as product_count,
+			COALESCE(SUM(order_items.quantity), 0) as total_sold,
+			COALESCE(SUM(order_items.quantity * order_items.unit_price), 0) as total_revenue,
+			COALESCE(AVG(order_items.unit_price), 0) as avg_price
+		FROM products
+		LEFT JOIN order_items ON order_items.product_id = products.id
+		LEFT JOIN orders ON orders.id = order_items.order_id
+	`
+
+	stats, err := queryAll(ctx, s, s.readDB(), func(rows *sql.Rows) (CategoryStats, error) {
+		var stat CategoryStats
+		err := rows.Scan(
+			&stat.ProductCount,
+			&stat.TotalSold,
+			&stat.TotalRevenue,
+			&stat.AvgPrice,
+		)
+		return stat, err
+	}, query)
+	if err == nil && len(stats) == 0 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return CategoryStats{}, recordError(span, fmt.Errorf("failed to query category stats: %w", err))
+	}
+	return stats[0], nil
+}
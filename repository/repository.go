@@ -0,0 +1,193 @@
+// Package repository holds the SQL data access of the sample application.
+//
+// Handlers depend on the OrderRepo, ProductRepo and UserRepo interfaces, so
+// they can be tested with fakes. Store implements all three on a *sql.DB
+// opened with dbm.NewConnector and otelsql: every query gets a span here,
+// the otelsql span below it and the DBM comment from the driver.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"otel-go-dbm/dbm"
+)
+
+const instrumentationName = "otel-go-dbm/repository"
+
+// ErrNotFound is returned when the requested row does not exist
+var ErrNotFound = errors.New("not found")
+
+// OrderRepo reads orders
+type OrderRepo interface {
+	// Details returns one row per item of the order, or ErrNotFound
+	Details(ctx context.Context, orderID uint64) ([]OrderDetail, error)
+	// Totals returns the number and total amount of the orders per status
+	Totals(ctx context.Context) ([]OrderTotals, error)
+}
+
+// ProductRepo reads product sales statistics
+type ProductRepo interface {
+	// SalesStats returns the 50 products with the highest revenue
+	SalesStats(ctx context.Context) ([]ProductSalesStats, error)
+	// CategoryStats returns the sales totals over all products
+	CategoryStats(ctx context.Context) (CategoryStats, error)
+}
+
+// UserRepo reads user order statistics
+type UserRepo interface {
+	// OrderAnalytics returns the 50 users with the highest order total
+	OrderAnalytics(ctx context.Context) ([]UserOrderStats, error)
+}
+
+// Config holds configuration for Store
+type Config struct {
+	// DB is the primary database
+	DB *sql.DB
+
+	// Replicas receive the read-only analytics queries in round robin.
+	// DB is used when empty.
+	Replicas []*sql.DB
+
+	// DriverName is the DB_DRIVER value: postgres, mysql or sqlserver
+	DriverName string
+
+	// DBName is recorded as db.name on the spans
+	DBName string
+
+	// Retrier retries queries that fail with a transient error.
+	// Queries run once when nil.
+	Retrier *dbm.Retrier
+
+	// StmtCacheSize enables a prepared statement cache of this many
+	// statements per database. Queries are not prepared when 0.
+	StmtCacheSize int
+}
+
+// Store implements OrderRepo, ProductRepo and UserRepo with SQL
+type Store struct {
+	config Config
+	next   atomic.Uint64
+	caches map[*sql.DB]*dbm.StmtCache
+	tracer trace.Tracer
+}
+
+// queryer is implemented by *sql.DB and *dbm.StmtCache
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+var (
+	_ OrderRepo   = (*Store)(nil)
+	_ ProductRepo = (*Store)(nil)
+	_ UserRepo    = (*Store)(nil)
+)
+
+// NewStore creates a Store
+func NewStore(config *Config) *Store {
+	cfg := *config
+	if cfg.Retrier == nil {
+		cfg.Retrier = dbm.NewRetrier(&dbm.RetryConfig{})
+	}
+	s := &Store{
+		config: cfg,
+		tracer: otel.GetTracerProvider().Tracer(instrumentationName),
+	}
+	if cfg.StmtCacheSize > 0 {
+		s.caches = make(map[*sql.DB]*dbm.StmtCache)
+		for _, db := range append([]*sql.DB{cfg.DB}, cfg.Replicas...) {
+			s.caches[db] = dbm.NewStmtCache(&dbm.StmtCacheConfig{DB: db, Size: cfg.StmtCacheSize})
+		}
+	}
+	return s
+}
+
+// Close closes the cached prepared statements. The databases stay open.
+func (s *Store) Close() error {
+	var errs []error
+	for _, cache := range s.caches {
+		errs = append(errs, cache.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// readDB returns the database for read-only queries, picking a replica in
+// round robin and falling back to the primary
+func (s *Store) readDB() *sql.DB {
+	if len(s.config.Replicas) == 0 {
+		return s.config.DB
+	}
+	return s.config.Replicas[s.next.Add(1)%uint64(len(s.config.Replicas))]
+}
+
+// queryer returns the statement cache of db, or db itself when caching is off
+func (s *Store) queryer(db *sql.DB) queryer {
+	if cache, ok := s.caches[db]; ok {
+		return cache
+	}
+	return db
+}
+
+// queryAll runs query on db and scans every row, retrying the whole query
+// when it fails with a transient error. The number of rows is recorded on
+// the span of ctx.
+func queryAll[T any](ctx context.Context, s *Store, db *sql.DB, scan func(*sql.Rows) (T, error), query string, args ...any) ([]T, error) {
+	var result []T
+	err := s.config.Retrier.Do(ctx, func(ctx context.Context) error {
+		// The repository span stays open while scanning, so it can hold
+		// the row events of the query lifecycle
+		rows, err := s.queryer(db).QueryContext(dbm.WithQueryEvents(ctx), query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		result = result[:0]
+		for rows.Next() {
+			v, err := scan(rows)
+			if err != nil {
+				return err
+			}
+			result = append(result, v)
+		}
+		return rows.Err()
+	})
+	if err == nil {
+		trace.SpanFromContext(ctx).SetAttributes(dbm.AttrReturnedRows.Int(len(result)))
+	}
+	return result, err
+}
+
+// startSpan starts the span of a repository method
+func (s *Store) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs,
+		semconv.DBOperation("SELECT"),
+		semconv.DBName(s.config.DBName),
+	)
+	return s.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// rebind rewrites ? placeholders for the driver
+func (s *Store) rebind(query string) string {
+	return sqlx.Rebind(sqlx.BindType(s.config.DriverName), query)
+}
+
+// recordError marks span as failed with err and returns err.
+// ErrNotFound is not a failure and is returned as is.
+func recordError(span trace.Span, err error) error {
+	if err == nil || errors.Is(err, ErrNotFound) {
+		return err
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return err
+}
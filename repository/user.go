@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// UserOrderStats is the order summary of one user
+type UserOrderStats struct {
+	UserID      uint    `json:"user_id"`
+	UserName    string  `json:"user_name"`
+	UserEmail   string  `json:"user_email"`
+	OrderCount  int64   `json:"order_count"`
+	TotalAmount float64 `json:"total_amount"`
+	AvgAmount   float64 `json:"avg_amount"`
+	ItemCount   int64   `json:"item_count"`
+}
+
+// OrderAnalytics returns the 50 users with the highest order total
+func (s *Store) OrderAnalytics(ctx context.Context) ([]UserOrderStats, error) {
+	ctx, span := s.startSpan(ctx, "UserRepo.OrderAnalytics")
+	defer span.End()
+
+	query := `
+		SELECT
+			users.id as user_id,
+			users.name as user_name,
+			users.email as user_email,
+			COUNT(DISTINCT orders.id) as order_count,
+			COALESCE(SUM(orders.total_amount), 0) as total_amount,
+			COALESCE(AVG(orders.total_amount), 0) as avg_amount,
+			COALESCE(SUM(order_items.quantity), 0) as item_count
+		FROM users
+		LEFT JOIN orders ON orders.user_id = users.id
+		LEFT JOIN order_items ON order_items.order_id = orders.id
+		GROUP BY users.id, users.name, users.email
+		ORDER BY total_amount DESC
+		LIMIT 50
+	`
+
+	stats, err := queryAll(ctx, s, s.readDB(), func(rows *sql.Rows) (UserOrderStats, error) {
+		var stat UserOrderStats
+		err := rows.Scan(
+			&stat.UserID,
+			&stat.UserName,
+			&stat.UserEmail,
+			&stat.OrderCount,
+			&stat.TotalAmount,
+			&stat.AvgAmount,
+			&stat.ItemCount,
+		)
+		return stat, err
+	}, query)
+	if err != nil {
+		return nil, recordError(span, fmt.Errorf("failed to query user order analytics: %w", err))
+	}
+	return stats, nil
+}
@@ -17,7 +17,10 @@ ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO "a
 -- 5. テーブル作成権限を付与（マイグレーション用）
 GRANT CREATE ON SCHEMA public TO "advent-user";
 
--- 6. 確認: 現在の権限を表示
+-- 6. 他セッションのクエリ統計の参照権限を付与（管理エンドポイント用）
+GRANT pg_read_all_stats TO "advent-user";
+
+-- 7. 確認: 現在の権限を表示
 SELECT 
     grantee,
     table_schema,
@@ -27,7 +30,7 @@ FROM information_schema.table_privileges
 WHERE grantee = 'advent-user'
 ORDER BY table_schema, table_name, privilege_type;
 
--- 7. 確認: スキーマ権限を表示
+-- 8. 確認: スキーマ権限を表示
 SELECT 
     nspname as schema_name,
     nspacl as privileges
@@ -0,0 +1,321 @@
+// Package seed fills the sample schema with random but plausible users,
+// products, orders and order items, so the analytics endpoints return
+// meaningful results and DBM has load to observe.
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"otel-go-dbm/dbm"
+)
+
+const instrumentationName = "otel-go-dbm/seed"
+
+// Rows are inserted in transactions of this many parent rows
+const batchSize = 100
+
+var (
+	firstNames = []string{"Haruto", "Yui", "Sota", "Hina", "Ren", "Mio", "Emma", "Liam", "Olivia", "Noah", "Ava", "Lucas", "Sofia", "Mateo", "Aiko", "Kenji"}
+	lastNames  = []string{"Sato", "Suzuki", "Takahashi", "Tanaka", "Ito", "Watanabe", "Smith", "Johnson", "Garcia", "Miller", "Martin", "Lopez", "Kim", "Nguyen"}
+	categories = map[string][]string{
+		"Electronics": {"Headphones", "Keyboard", "Monitor", "Charger", "Speaker", "Webcam"},
+		"Books":       {"Novel", "Cookbook", "Guide", "Anthology", "Workbook"},
+		"Home":        {"Lamp", "Mug", "Blanket", "Vase", "Chair", "Clock"},
+		"Sports":      {"Running Shoes", "Yoga Mat", "Water Bottle", "Tennis Racket", "Backpack"},
+		"Grocery":     {"Coffee Beans", "Green Tea", "Olive Oil", "Chocolate", "Rice"},
+	}
+	adjectives = []string{"Classic", "Premium", "Compact", "Deluxe", "Eco", "Smart", "Vintage", "Ultra"}
+	statuses   = []string{"pending", "paid", "shipped", "delivered", "delivered", "delivered", "cancelled"}
+)
+
+// Config holds configuration for Run
+type Config struct {
+	Users    int
+	Products int
+	Orders   int
+
+	// MaxItems is the maximum number of items per order
+	MaxItems int
+
+	// Orders are dated uniformly between From and To
+	From time.Time
+	To   time.Time
+
+	// Seed makes the generated data reproducible. 0 picks a random seed.
+	Seed uint64
+}
+
+// Result counts the inserted rows
+type Result struct {
+	Users      int
+	Products   int
+	Orders     int
+	OrderItems int
+}
+
+type product struct {
+	id    int64
+	price float64
+}
+
+type seeder struct {
+	db         *sql.DB
+	driverName string
+	config     Config
+	rand       *rand.Rand
+	tracer     trace.Tracer
+
+	ordersCreated metric.Int64Counter   // app.orders.created
+	revenue       metric.Float64Counter // app.orders.revenue.processed
+}
+
+// Run inserts the configured number of rows into db. driverName is the
+// DB_DRIVER value: postgres, mysql or sqlserver. The tables must exist.
+func Run(ctx context.Context, db *sql.DB, driverName string, config *Config) (Result, error) {
+	cfg := *config
+	if cfg.MaxItems < 1 {
+		cfg.MaxItems = 1
+	}
+	if cfg.To.IsZero() {
+		cfg.To = time.Now()
+	}
+	if cfg.From.IsZero() || !cfg.From.Before(cfg.To) {
+		cfg.From = cfg.To.AddDate(-1, 0, 0)
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = rand.Uint64()
+	}
+
+	s := &seeder{
+		db:         db,
+		driverName: driverName,
+		config:     cfg,
+		rand:       rand.New(rand.NewPCG(cfg.Seed, cfg.Seed)),
+		tracer:     otel.GetTracerProvider().Tracer(instrumentationName),
+	}
+	meter := otel.GetMeterProvider().Meter(instrumentationName)
+	var err error
+	if s.ordersCreated, err = meter.Int64Counter("app.orders.created",
+		metric.WithDescription("Number of orders created"),
+		metric.WithUnit("{order}"),
+	); err != nil {
+		otel.Handle(err)
+	}
+	if s.revenue, err = meter.Float64Counter("app.orders.revenue.processed",
+		metric.WithDescription("Total amount of the orders created"),
+		metric.WithUnit("{currency}"),
+	); err != nil {
+		otel.Handle(err)
+	}
+
+	ctx, span := s.tracer.Start(ctx, "seed", trace.WithAttributes(
+		attribute.Int("seed.users", cfg.Users),
+		attribute.Int("seed.products", cfg.Products),
+		attribute.Int("seed.orders", cfg.Orders),
+		attribute.Int64("seed.seed", int64(cfg.Seed)),
+	))
+	defer span.End()
+
+	var result Result
+	userIDs, err := s.users(ctx)
+	result.Users = len(userIDs)
+	if err != nil {
+		return result, recordError(span, err)
+	}
+	products, err := s.products(ctx)
+	result.Products = len(products)
+	if err != nil {
+		return result, recordError(span, err)
+	}
+	if cfg.Orders > 0 && (len(userIDs) == 0 || len(products) == 0) {
+		return result, recordError(span, errors.New("orders need at least one user and one product"))
+	}
+	result.Orders, result.OrderItems, err = s.orders(ctx, userIDs, products)
+	if err != nil {
+		return result, recordError(span, err)
+	}
+	return result, nil
+}
+
+// users inserts the users and returns their IDs
+func (s *seeder) users(ctx context.Context) ([]int64, error) {
+	// A per-run prefix keeps emails unique when seeding the same database twice
+	prefix := fmt.Sprintf("%08x", uint32(s.config.Seed))
+	ids := make([]int64, 0, s.config.Users)
+	var batch []int64
+	err := s.batches(ctx, "seed.users", s.config.Users, func(ctx context.Context, tx *sql.Tx, i int) error {
+		first := firstNames[s.rand.IntN(len(firstNames))]
+		last := lastNames[s.rand.IntN(len(lastNames))]
+		email := fmt.Sprintf("%s.%s.%s%d@example.com", strings.ToLower(first), strings.ToLower(last), prefix, i)
+		id, err := s.insert(ctx, tx, "users", []string{"name", "email"}, first+" "+last, email)
+		if err != nil {
+			return err
+		}
+		batch = append(batch, id)
+		return nil
+	}, func() {
+		ids = append(ids, batch...)
+		batch = batch[:0]
+	})
+	return ids, err
+}
+
+// products inserts the products and returns their IDs and prices
+func (s *seeder) products(ctx context.Context) ([]product, error) {
+	names := make([]string, 0, len(categories))
+	for c := range categories {
+		names = append(names, c)
+	}
+	// Map order is random; sort so a fixed seed gives the same products
+	sort.Strings(names)
+
+	products := make([]product, 0, s.config.Products)
+	var batch []product
+	err := s.batches(ctx, "seed.products", s.config.Products, func(ctx context.Context, tx *sql.Tx, i int) error {
+		category := names[s.rand.IntN(len(names))]
+		nouns := categories[category]
+		name := adjectives[s.rand.IntN(len(adjectives))] + " " + nouns[s.rand.IntN(len(nouns))]
+		// Log-uniform prices between 5 and 500 look more like a real catalog
+		price := math.Round(5*math.Pow(100, s.rand.Float64())*100) / 100
+		id, err := s.insert(ctx, tx, "products", []string{"name", "category", "price"}, name, category, price)
+		if err != nil {
+			return err
+		}
+		batch = append(batch, product{id: id, price: price})
+		return nil
+	}, func() {
+		products = append(products, batch...)
+		batch = batch[:0]
+	})
+	return products, err
+}
+
+// orders inserts the orders with their items and returns both counts.
+// The orders and their amounts are counted in app.orders.created and
+// app.orders.revenue.processed once their batch is committed.
+func (s *seeder) orders(ctx context.Context, userIDs []int64, products []product) (int, int, error) {
+	type created struct {
+		status string
+		amount float64
+	}
+	dateRange := s.config.To.Sub(s.config.From)
+	orders, items := 0, 0
+	var batch []created
+	batchItems := 0
+	err := s.batches(ctx, "seed.orders", s.config.Orders, func(ctx context.Context, tx *sql.Tx, i int) error {
+		type item struct {
+			product  product
+			quantity int
+		}
+		lines := make([]item, 1+s.rand.IntN(s.config.MaxItems))
+		total := 0.0
+		for j := range lines {
+			lines[j] = item{product: products[s.rand.IntN(len(products))], quantity: 1 + s.rand.IntN(5)}
+			total += lines[j].product.price * float64(lines[j].quantity)
+		}
+
+		orderDate := s.config.From.Add(time.Duration(s.rand.Int64N(int64(dateRange)))).UTC()
+		status := statuses[s.rand.IntN(len(statuses))]
+		amount := math.Round(total*100) / 100
+		orderID, err := s.insert(ctx, tx, "orders", []string{"user_id", "status", "order_date", "total_amount"},
+			userIDs[s.rand.IntN(len(userIDs))], status, orderDate, amount)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if _, err := s.insert(ctx, tx, "order_items", []string{"order_id", "product_id", "quantity", "unit_price"},
+				orderID, line.product.id, line.quantity, line.product.price); err != nil {
+				return err
+			}
+		}
+		batch = append(batch, created{status: status, amount: amount})
+		batchItems += len(lines)
+		return nil
+	}, func() {
+		for _, order := range batch {
+			status := metric.WithAttributes(attribute.String("order.status", order.status))
+			s.ordersCreated.Add(ctx, 1, status)
+			s.revenue.Add(ctx, order.amount, status)
+		}
+		orders += len(batch)
+		items += batchItems
+		batch, batchItems = batch[:0], 0
+	})
+	return orders, items, err
+}
+
+// batches calls fn n times inside a span, committing every batchSize calls.
+// committed is called after each commit, so callers count only the rows of
+// committed batches and not those of a batch that was rolled back.
+func (s *seeder) batches(ctx context.Context, name string, n int, fn func(ctx context.Context, tx *sql.Tx, i int) error, committed func()) error {
+	ctx, span := s.tracer.Start(ctx, name, trace.WithAttributes(attribute.Int("seed.rows", n)))
+	defer span.End()
+
+	for start := 0; start < n; start += batchSize {
+		end := min(start+batchSize, n)
+		err := dbm.WithTx(ctx, s.db, func(ctx context.Context, tx *sql.Tx) error {
+			for i := start; i < end; i++ {
+				if err := fn(ctx, tx, i); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return recordError(span, err)
+		}
+		committed()
+	}
+	return nil
+}
+
+// insert inserts one row and returns its generated id
+func (s *seeder) insert(ctx context.Context, tx *sql.Tx, table string, columns []string, args ...any) (int64, error) {
+	cols := strings.Join(columns, ", ")
+	values := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+
+	var id int64
+	switch s.driverName {
+	case "mysql":
+		result, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, cols, values), args...)
+		if err != nil {
+			return 0, err
+		}
+		return result.LastInsertId()
+	case "sqlserver":
+		query := fmt.Sprintf("INSERT INTO %s (%s) OUTPUT INSERTED.id VALUES (%s)", table, cols, values)
+		err := tx.QueryRowContext(ctx, s.rebind(query), args...).Scan(&id)
+		return id, err
+	default:
+		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING id", table, cols, values)
+		err := tx.QueryRowContext(ctx, s.rebind(query), args...).Scan(&id)
+		return id, err
+	}
+}
+
+// rebind rewrites ? placeholders for the driver
+func (s *seeder) rebind(query string) string {
+	return sqlx.Rebind(sqlx.BindType(s.driverName), query)
+}
+
+// recordError marks span as failed with err and returns err
+func recordError(span trace.Span, err error) error {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return err
+}
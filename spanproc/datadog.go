@@ -0,0 +1,167 @@
+package spanproc
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attributes the Datadog Agent maps to the operation and resource name of
+// OTLP spans
+const (
+	KeyOperationName = attribute.Key("operation.name")
+	KeyResourceName  = attribute.Key("resource.name")
+)
+
+// Operation names of database systems that differ from db.system
+var dbOperationNames = map[string]string{
+	semconv.DBSystemPostgreSQL.Value.AsString(): "postgres.query",
+	semconv.DBSystemMSSQL.Value.AsString():      "sqlserver.query",
+	semconv.DBSystemRedis.Value.AsString():      "redis.command",
+}
+
+// Attributes of the HTTP method in current and pre-1.21 semantic conventions
+var httpMethodKeys = []attribute.Key{"http.request.method", "http.method"}
+
+// DatadogNamingConfig holds configuration for DatadogNamingProcessor
+type DatadogNamingConfig struct {
+	// Next receives the renamed spans, typically the batch span processor of
+	// the exporter. Required.
+	Next sdktrace.SpanProcessor
+}
+
+// DatadogNamingProcessor sets operation.name and resource.name on ended spans
+// following the Datadog conventions, so spans ingested over OTLP are grouped
+// like the spans of the Datadog tracers:
+//
+//   - database spans: <system>.query (postgres.query, redis.command) with
+//     the obfuscated statement as resource
+//   - HTTP spans: http.request (server) or http.client.request (client)
+//     with "METHOD route" as resource
+//   - messaging spans: <system>.<operation> with the destination as resource
+//
+// Attributes already set on a span are kept, and spans matching none of
+// the above are passed on unchanged.
+type DatadogNamingProcessor struct {
+	next sdktrace.SpanProcessor
+}
+
+var _ sdktrace.SpanProcessor = (*DatadogNamingProcessor)(nil)
+
+// NewDatadogNamingProcessor creates a new DatadogNamingProcessor
+func NewDatadogNamingProcessor(config *DatadogNamingConfig) *DatadogNamingProcessor {
+	return &DatadogNamingProcessor{next: config.Next}
+}
+
+// OnStart passes the span to the next processor
+func (p *DatadogNamingProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, s)
+}
+
+// OnEnd sets the operation and resource name of s and passes it to the next
+// processor
+func (p *DatadogNamingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	operation, resource := datadogNames(s)
+	if operation == "" {
+		p.next.OnEnd(s)
+		return
+	}
+
+	_, hasOperation := attributeValue(s, KeyOperationName)
+	_, hasResource := attributeValue(s, KeyResourceName)
+	if hasOperation && (hasResource || resource == "") {
+		p.next.OnEnd(s)
+		return
+	}
+
+	attrs := append([]attribute.KeyValue(nil), s.Attributes()...)
+	if !hasOperation {
+		attrs = append(attrs, KeyOperationName.String(operation))
+	}
+	if !hasResource && resource != "" {
+		attrs = append(attrs, KeyResourceName.String(resource))
+	}
+	p.next.OnEnd(rewrittenSpan{ReadOnlySpan: s, attrs: attrs, events: s.Events()})
+}
+
+// Shutdown shuts down the next processor
+func (p *DatadogNamingProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+// ForceFlush flushes the next processor
+func (p *DatadogNamingProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// datadogNames returns the operation and resource name of s, or "" when s
+// is not a database, HTTP or messaging span
+func datadogNames(s sdktrace.ReadOnlySpan) (operation, resource string) {
+	if system, ok := attributeValue(s, semconv.DBSystemKey); ok {
+		return dbNames(s, system.AsString())
+	}
+	if system, ok := attributeValue(s, semconv.MessagingSystemKey); ok {
+		operation = system.AsString() + ".process"
+		if op, ok := attributeValue(s, semconv.MessagingOperationKey); ok {
+			operation = system.AsString() + "." + op.AsString()
+		}
+		resource = s.Name()
+		if dest, ok := attributeValue(s, semconv.MessagingDestinationNameKey); ok {
+			resource = dest.AsString()
+		}
+		return operation, resource
+	}
+	for _, key := range httpMethodKeys {
+		if method, ok := attributeValue(s, key); ok {
+			return httpNames(s, method.AsString())
+		}
+	}
+	return "", ""
+}
+
+// dbNames returns the names of a database span
+func dbNames(s sdktrace.ReadOnlySpan, system string) (operation, resource string) {
+	operation = system + ".query"
+	if name, ok := dbOperationNames[system]; ok {
+		operation = name
+	}
+
+	switch system {
+	case semconv.DBSystemMongoDB.Value.AsString():
+		// The statement is a JSON command; operation and collection
+		// identify it without its values
+		resource = s.Name()
+		if op, ok := attributeValue(s, semconv.DBOperationKey); ok {
+			resource = op.AsString()
+			if coll, ok := attributeValue(s, semconv.DBMongoDBCollectionKey); ok {
+				resource += " " + coll.AsString()
+			}
+		}
+	case semconv.DBSystemRedis.Value.AsString():
+		// Keys of Redis commands are often values (session IDs, emails)
+		resource = s.Name()
+	default:
+		resource = s.Name()
+		if stmt, ok := attributeValue(s, semconv.DBStatementKey); ok && stmt.AsString() != "" {
+			resource = strings.Join(strings.Fields(ObfuscateSQL(stmt.AsString())), " ")
+		}
+	}
+	return operation, resource
+}
+
+// httpNames returns the names of an HTTP span
+func httpNames(s sdktrace.ReadOnlySpan, method string) (operation, resource string) {
+	operation = "http.request"
+	if s.SpanKind() == trace.SpanKindClient {
+		operation = "http.client.request"
+	}
+	resource = method
+	if route, ok := attributeValue(s, semconv.HTTPRouteKey); ok && route.AsString() != "" {
+		resource += " " + route.AsString()
+	}
+	return operation, resource
+}
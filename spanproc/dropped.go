@@ -0,0 +1,145 @@
+package spanproc
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const instrumentationName = "otel-go-dbm/spanproc"
+
+// The SDK logs the running total of dropped spans before each export
+const (
+	exportLogMessage = "exporting spans"
+	exportLogDropped = "total_dropped"
+)
+
+// Verbosity of the SDK's warnings and debug messages (see otel.SetLogger)
+const (
+	sdkWarnLevel  = 1
+	sdkDebugLevel = 8
+)
+
+// DropMonitorConfig holds configuration for DropMonitor
+type DropMonitorConfig struct {
+	// Interval is the time between warnings while spans are being dropped.
+	// Defaults to 1m.
+	Interval time.Duration
+}
+
+// DropMonitor reports the spans the batch span processor drops because its
+// queue is full, as the otel.sdk.span.dropped counter and a periodic
+// warning, instead of dropping them silently.
+//
+// The SDK keeps the count to itself and only logs the running total at
+// debug level before each export, so DropMonitor is a logr.LogSink that
+// reads it: install it with otel.SetLogger(logr.New(m)). The SDK's errors
+// and warnings are passed on to slog.
+type DropMonitor struct {
+	config   DropMonitorConfig
+	dropped  metric.Int64Counter
+	total    atomic.Int64
+	reported atomic.Int64
+}
+
+var _ logr.LogSink = (*DropMonitor)(nil)
+
+// NewDropMonitor creates a new DropMonitor
+func NewDropMonitor(config *DropMonitorConfig) *DropMonitor {
+	var cfg DropMonitorConfig
+	if config != nil {
+		cfg = *config
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+
+	dropped, err := otel.GetMeterProvider().Meter(instrumentationName).Int64Counter("otel.sdk.span.dropped",
+		metric.WithDescription("Number of spans dropped because the export queue was full"),
+		metric.WithUnit("{span}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	return &DropMonitor{config: cfg, dropped: dropped}
+}
+
+// Run calls Report every Interval until ctx is done
+func (m *DropMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Report(ctx)
+		}
+	}
+}
+
+// Dropped returns the number of spans dropped so far
+func (m *DropMonitor) Dropped() int64 {
+	return m.total.Load()
+}
+
+// Report logs a warning when spans were dropped since the last report
+func (m *DropMonitor) Report(ctx context.Context) {
+	total := m.total.Load()
+	if n := total - m.reported.Swap(total); n > 0 {
+		slog.WarnContext(ctx, "Spans dropped because the export queue is full",
+			"dropped", n,
+			"total_dropped", total,
+		)
+	}
+}
+
+// Init does nothing
+func (m *DropMonitor) Init(logr.RuntimeInfo) {}
+
+// Enabled accepts the SDK's errors, warnings and debug messages
+func (m *DropMonitor) Enabled(level int) bool {
+	return level <= sdkWarnLevel || level == sdkDebugLevel
+}
+
+// Info records the dropped total of the export debug message and logs
+// warnings
+func (m *DropMonitor) Info(level int, msg string, keysAndValues ...any) {
+	if msg == exportLogMessage {
+		for i := 0; i+1 < len(keysAndValues); i += 2 {
+			if keysAndValues[i] == exportLogDropped {
+				if total, ok := keysAndValues[i+1].(uint32); ok {
+					m.record(int64(total))
+				}
+			}
+		}
+		return
+	}
+	if level <= sdkWarnLevel {
+		slog.Warn(msg, keysAndValues...)
+	}
+}
+
+// Error logs the SDK error
+func (m *DropMonitor) Error(err error, msg string, keysAndValues ...any) {
+	slog.Error(msg, append(keysAndValues, "error", err)...)
+}
+
+// WithValues returns m; the SDK does not use logger values
+func (m *DropMonitor) WithValues(...any) logr.LogSink { return m }
+
+// WithName returns m; the SDK does not use logger names
+func (m *DropMonitor) WithName(string) logr.LogSink { return m }
+
+// record counts the spans dropped since the previous total
+func (m *DropMonitor) record(total int64) {
+	if n := total - m.total.Swap(total); n > 0 && m.dropped != nil {
+		m.dropped.Add(context.Background(), n)
+	}
+}
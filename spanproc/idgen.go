@@ -0,0 +1,76 @@
+package spanproc
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand/v2"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceIDMode selects the layout of the trace IDs of DatadogIDGenerator
+type TraceIDMode int
+
+const (
+	// TraceID128 generates 128-bit trace IDs the way Datadog tracers do: the
+	// upper 64 bits are the start time in Unix seconds followed by 32 zero
+	// bits, the lower 64 bits are random
+	TraceID128 TraceIDMode = iota
+
+	// TraceID64 leaves the upper 64 bits zero, for agents and log pipelines
+	// that only know 64-bit trace IDs
+	TraceID64
+)
+
+// DatadogIDGeneratorConfig holds configuration for DatadogIDGenerator
+type DatadogIDGeneratorConfig struct {
+	// Mode is the trace ID layout. Defaults to TraceID128.
+	Mode TraceIDMode
+}
+
+// DatadogIDGenerator generates trace IDs whose lower 64 bits are random, so
+// the lower half alone identifies a trace in Datadog's legacy 64-bit
+// correlation (dd.trace_id in logs, older agents), while the full 128-bit
+// ID still matches the traceparent in SQL comments
+type DatadogIDGenerator struct {
+	mode TraceIDMode
+}
+
+var _ sdktrace.IDGenerator = (*DatadogIDGenerator)(nil)
+
+// NewDatadogIDGenerator creates a new DatadogIDGenerator
+func NewDatadogIDGenerator(config *DatadogIDGeneratorConfig) *DatadogIDGenerator {
+	var cfg DatadogIDGeneratorConfig
+	if config != nil {
+		cfg = *config
+	}
+	return &DatadogIDGenerator{mode: cfg.Mode}
+}
+
+// NewIDs returns a new trace ID and span ID
+func (g *DatadogIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	var tid trace.TraceID
+	if g.mode == TraceID128 {
+		binary.BigEndian.PutUint32(tid[:4], uint32(time.Now().Unix()))
+	}
+	binary.BigEndian.PutUint64(tid[8:], nonZero())
+	return tid, g.NewSpanID(ctx, tid)
+}
+
+// NewSpanID returns a new span ID
+func (g *DatadogIDGenerator) NewSpanID(context.Context, trace.TraceID) trace.SpanID {
+	var sid trace.SpanID
+	binary.BigEndian.PutUint64(sid[:], nonZero())
+	return sid
+}
+
+// nonZero returns a random number other than 0, which is an invalid ID
+func nonZero() uint64 {
+	for {
+		if v := rand.Uint64(); v != 0 {
+			return v
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package spanproc
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// DefaultAllowlist are the statements exported unchanged by default
+var DefaultAllowlist = []string{"SELECT 1", "BEGIN", "COMMIT", "ROLLBACK"}
+
+// ObfuscateConfig holds configuration for ObfuscateProcessor
+type ObfuscateConfig struct {
+	// Next receives the spans with obfuscated statements, typically the
+	// batch span processor of the exporter. Required.
+	Next sdktrace.SpanProcessor
+
+	// Keys are the attributes holding SQL statements. Defaults to db.statement.
+	Keys []attribute.Key
+
+	// Allowlist are statements exported unchanged, compared case-insensitively
+	// with whitespace collapsed. Defaults to DefaultAllowlist when nil.
+	Allowlist []string
+}
+
+// ObfuscateProcessor replaces the literals of the SQL statements on ended
+// spans with ? before passing them to the next processor, so statements can
+// stay on the spans without leaking the values they were run with
+type ObfuscateProcessor struct {
+	next      sdktrace.SpanProcessor
+	keys      []attribute.Key
+	allowlist map[string]bool
+}
+
+var _ sdktrace.SpanProcessor = (*ObfuscateProcessor)(nil)
+
+// NewObfuscateProcessor creates a new ObfuscateProcessor
+func NewObfuscateProcessor(config *ObfuscateConfig) *ObfuscateProcessor {
+	cfg := *config
+	if len(cfg.Keys) == 0 {
+		cfg.Keys = []attribute.Key{semconv.DBStatementKey}
+	}
+	if cfg.Allowlist == nil {
+		cfg.Allowlist = DefaultAllowlist
+	}
+
+	allowlist := make(map[string]bool, len(cfg.Allowlist))
+	for _, stmt := range cfg.Allowlist {
+		allowlist[normalizeStatement(stmt)] = true
+	}
+	return &ObfuscateProcessor{
+		next:      cfg.Next,
+		keys:      cfg.Keys,
+		allowlist: allowlist,
+	}
+}
+
+// OnStart passes the span to the next processor
+func (p *ObfuscateProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, s)
+}
+
+// OnEnd obfuscates the statements of s and passes it to the next processor
+func (p *ObfuscateProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.next.OnEnd(rewriteAttributes(s, func(attrs []attribute.KeyValue) bool {
+		changed := false
+		for i, attr := range attrs {
+			if !p.isStatement(attr.Key) || attr.Value.Type() != attribute.STRING {
+				continue
+			}
+			stmt := attr.Value.AsString()
+			if p.allowlist[normalizeStatement(stmt)] {
+				continue
+			}
+			if obfuscated := ObfuscateSQL(stmt); obfuscated != stmt {
+				attrs[i] = attr.Key.String(obfuscated)
+				changed = true
+			}
+		}
+		return changed
+	}))
+}
+
+// Shutdown shuts down the next processor
+func (p *ObfuscateProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+// ForceFlush flushes the next processor
+func (p *ObfuscateProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// isStatement reports whether key holds a statement to obfuscate
+func (p *ObfuscateProcessor) isStatement(key attribute.Key) bool {
+	for _, k := range p.keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeStatement uppercases stmt and collapses its whitespace
+func normalizeStatement(stmt string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(stmt), " "))
+}
+
+// Lists of placeholders, e.g. IN (?, ?, ?)
+var placeholderList = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)+\s*\)`)
+
+// ObfuscateSQL replaces the string and number literals of query with ? and
+// collapses lists of them into (?). Comments, quoted identifiers and bind
+// parameters ($1, ?, @p1, :name) are kept.
+//
+// Backslashes escape the next character in strings, as in MySQL. On
+// PostgreSQL this can only make a literal end later, so more is replaced,
+// never less.
+func ObfuscateSQL(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i
+			} else {
+				end += 4
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case c == '"' || c == '`':
+			end := skipQuoted(query, i, c, false)
+			b.WriteString(query[i:end])
+			i = end
+		case c == '\'':
+			i = skipQuoted(query, i, c, true)
+			b.WriteByte('?')
+		case c == '$' && dollarTag(query[i:]) != "":
+			tag := dollarTag(query[i:])
+			end := strings.Index(query[i+len(tag):], tag)
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += len(tag) + end + len(tag)
+			}
+			b.WriteByte('?')
+		case isDigit(c) || c == '.' && i+1 < len(query) && isDigit(query[i+1]):
+			i = skipNumber(query, i)
+			b.WriteByte('?')
+		case isIdentStart(c) || c == '$' || c == '@' || c == ':':
+			start := i
+			for i++; i < len(query) && isIdentPart(query[i]); i++ {
+			}
+			// String constants with a prefix: E'…', B'…', X'…', N'…'
+			if i-start == 1 && i < len(query) && query[i] == '\'' && strings.ContainsRune("eEbBxXnN", rune(c)) {
+				i = skipQuoted(query, i, '\'', true)
+				b.WriteByte('?')
+				continue
+			}
+			b.WriteString(query[start:i])
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return placeholderList.ReplaceAllString(b.String(), "(?)")
+}
+
+// skipQuoted returns the index after the quoted text starting at query[i].
+// Doubled quotes, and backslash escapes when backslash is set, do not end it.
+func skipQuoted(query string, i int, quote byte, backslash bool) int {
+	for i++; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if backslash {
+				i++
+			}
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(query)
+}
+
+// dollarTag returns the $tag$ opening a PostgreSQL dollar-quoted string at
+// the start of s, or "" when s does not start with one
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '$':
+			return s[:i+1]
+		case isIdentStart(s[i]) || i > 1 && isDigit(s[i]):
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// skipNumber returns the index after the number starting at query[i]:
+// decimals, exponents and hexadecimal (0x…) numbers
+func skipNumber(query string, i int) int {
+	if strings.HasPrefix(query[i:], "0x") || strings.HasPrefix(query[i:], "0X") {
+		for i += 2; i < len(query) && isIdentPart(query[i]); i++ {
+		}
+		return i
+	}
+	for ; i < len(query); i++ {
+		c := query[i]
+		if (c == '+' || c == '-') && (query[i-1] == 'e' || query[i-1] == 'E') {
+			continue
+		}
+		if !isDigit(c) && c != '.' && c != 'e' && c != 'E' {
+			break
+		}
+	}
+	return i
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '$'
+}
@@ -0,0 +1,130 @@
+package spanproc
+
+import (
+	"context"
+	"net"
+	"net/url"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// KeyPeerService is the logical name of the service a client span calls
+const KeyPeerService = attribute.Key("peer.service")
+
+// Attributes of the remote host and port in current and pre-1.21 semantic
+// conventions, and of the URL of HTTP client spans
+var (
+	peerHostKeys = []attribute.Key{semconv.ServerAddressKey, "net.peer.name"}
+	peerPortKeys = []attribute.Key{semconv.ServerPortKey, "net.peer.port"}
+	peerURLKeys  = []attribute.Key{semconv.URLFullKey, "http.url"}
+)
+
+// PeerServiceConfig holds configuration for PeerServiceProcessor
+type PeerServiceConfig struct {
+	// Next receives the enriched spans, typically the batch span processor
+	// of the exporter. Required.
+	Next sdktrace.SpanProcessor
+
+	// Services maps a remote host, or host:port, to its service name.
+	// host:port takes precedence over host.
+	Services map[string]string
+}
+
+// PeerServiceProcessor sets peer.service on ended client spans from the
+// host they called (server.address, net.peer.name or the URL of HTTP client
+// spans), so service maps connect the application to its databases and
+// downstream services. Spans that already have peer.service are kept.
+type PeerServiceProcessor struct {
+	next     sdktrace.SpanProcessor
+	services map[string]string
+}
+
+var _ sdktrace.SpanProcessor = (*PeerServiceProcessor)(nil)
+
+// NewPeerServiceProcessor creates a new PeerServiceProcessor
+func NewPeerServiceProcessor(config *PeerServiceConfig) *PeerServiceProcessor {
+	return &PeerServiceProcessor{
+		next:     config.Next,
+		services: config.Services,
+	}
+}
+
+// OnStart passes the span to the next processor
+func (p *PeerServiceProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, s)
+}
+
+// OnEnd sets peer.service on s and passes it to the next processor
+func (p *PeerServiceProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanKind() != trace.SpanKindClient {
+		p.next.OnEnd(s)
+		return
+	}
+	if _, ok := attributeValue(s, KeyPeerService); ok {
+		p.next.OnEnd(s)
+		return
+	}
+	service, ok := p.lookup(peerAddress(s))
+	if !ok {
+		p.next.OnEnd(s)
+		return
+	}
+
+	attrs := append(append([]attribute.KeyValue(nil), s.Attributes()...), KeyPeerService.String(service))
+	p.next.OnEnd(rewrittenSpan{ReadOnlySpan: s, attrs: attrs, events: s.Events()})
+}
+
+// Shutdown shuts down the next processor
+func (p *PeerServiceProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+// ForceFlush flushes the next processor
+func (p *PeerServiceProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// lookup returns the service of host:port, falling back to host
+func (p *PeerServiceProcessor) lookup(host, port string) (string, bool) {
+	if host == "" {
+		return "", false
+	}
+	if port != "" {
+		if service, ok := p.services[net.JoinHostPort(host, port)]; ok {
+			return service, true
+		}
+	}
+	service, ok := p.services[host]
+	return service, ok
+}
+
+// peerAddress returns the remote host and port of s, or "" when unknown
+func peerAddress(s sdktrace.ReadOnlySpan) (host, port string) {
+	for _, key := range peerHostKeys {
+		if v, ok := attributeValue(s, key); ok {
+			host = v.AsString()
+			break
+		}
+	}
+	for _, key := range peerPortKeys {
+		if v, ok := attributeValue(s, key); ok {
+			port = v.Emit()
+			break
+		}
+	}
+	if host != "" {
+		return host, port
+	}
+
+	for _, key := range peerURLKeys {
+		if v, ok := attributeValue(s, key); ok {
+			if u, err := url.Parse(v.AsString()); err == nil {
+				return u.Hostname(), u.Port()
+			}
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,121 @@
+package spanproc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// QueueMonitorConfig holds configuration for QueueMonitor
+type QueueMonitorConfig struct {
+	// Drops counts the spans the batch span processor drops, which never
+	// reach the exporter. Optional.
+	Drops *DropMonitor
+}
+
+// QueueMonitor tracks the spans waiting in the queue of a batch span
+// processor and the result of its last export, which the SDK does not
+// expose. Wrap the exporter of the batch span processor with Exporter and
+// the batch span processor itself with Processor.
+type QueueMonitor struct {
+	drops    *DropMonitor
+	enqueued atomic.Int64
+	exported atomic.Int64
+
+	mu         sync.Mutex
+	lastExport time.Time
+	lastErr    error
+}
+
+// NewQueueMonitor creates a new QueueMonitor
+func NewQueueMonitor(config *QueueMonitorConfig) *QueueMonitor {
+	var cfg QueueMonitorConfig
+	if config != nil {
+		cfg = *config
+	}
+	return &QueueMonitor{drops: cfg.Drops}
+}
+
+// Depth returns the number of spans waiting to be exported. Dropped spans
+// are only counted at the next export, so the depth can exceed the queue
+// size while the queue is full.
+func (m *QueueMonitor) Depth() int64 {
+	depth := m.enqueued.Load() - m.exported.Load()
+	if m.drops != nil {
+		depth -= m.drops.Dropped()
+	}
+	return max(depth, 0)
+}
+
+// LastExport returns the time and error of the last export. The time is
+// zero before the first export.
+func (m *QueueMonitor) LastExport() (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastExport, m.lastErr
+}
+
+// Processor returns a SpanProcessor that counts the sampled spans next, the
+// batch span processor, enqueues
+func (m *QueueMonitor) Processor(next sdktrace.SpanProcessor) sdktrace.SpanProcessor {
+	return &queueProcessor{next: next, m: m}
+}
+
+// Exporter returns a SpanExporter that counts the spans next exports
+func (m *QueueMonitor) Exporter(next sdktrace.SpanExporter) sdktrace.SpanExporter {
+	return &queueExporter{next: next, m: m}
+}
+
+type queueProcessor struct {
+	next sdktrace.SpanProcessor
+	m    *QueueMonitor
+}
+
+// OnStart passes the span on
+func (p *queueProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(parent, s)
+}
+
+// OnEnd counts the span when the batch span processor enqueues it
+func (p *queueProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		p.m.enqueued.Add(1)
+	}
+	p.next.OnEnd(s)
+}
+
+// Shutdown shuts down the next processor
+func (p *queueProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+// ForceFlush flushes the next processor
+func (p *queueProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+type queueExporter struct {
+	next sdktrace.SpanExporter
+	m    *QueueMonitor
+}
+
+// ExportSpans exports the spans and records the result; the spans leave
+// the queue whether the export succeeds or not
+func (e *queueExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.next.ExportSpans(ctx, spans)
+	e.m.exported.Add(int64(len(spans)))
+
+	e.m.mu.Lock()
+	e.m.lastExport = time.Now()
+	e.m.lastErr = err
+	e.m.mu.Unlock()
+	return err
+}
+
+// Shutdown shuts down the next exporter
+func (e *queueExporter) Shutdown(ctx context.Context) error {
+	return e.next.Shutdown(ctx)
+}
@@ -0,0 +1,160 @@
+package spanproc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// RedactAction is what RedactProcessor does with a matching attribute
+type RedactAction int
+
+const (
+	// RedactDrop removes the attribute
+	RedactDrop RedactAction = iota
+	// RedactHash replaces the value with its SHA-256 (HMAC-SHA256 with
+	// RedactConfig.HashKey), so equal values can still be grouped
+	RedactHash
+)
+
+// RedactRule selects attributes by exact key or by a pattern on the key
+type RedactRule struct {
+	Keys    []attribute.Key
+	Pattern *regexp.Regexp
+	Action  RedactAction
+}
+
+// matches reports whether the rule selects key
+func (r RedactRule) matches(key attribute.Key) bool {
+	for _, k := range r.Keys {
+		if k == key {
+			return true
+		}
+	}
+	return r.Pattern != nil && r.Pattern.MatchString(string(key))
+}
+
+// RedactConfig holds configuration for RedactProcessor
+type RedactConfig struct {
+	// Next receives the redacted spans, typically the batch span processor
+	// of the exporter. Required.
+	Next sdktrace.SpanProcessor
+
+	// Rules are tried in order; the first matching rule applies
+	Rules []RedactRule
+
+	// HashKey keys the hash of RedactHash. Without it, short values such as
+	// email addresses can be recovered by hashing candidates.
+	HashKey []byte
+}
+
+// RedactProcessor drops or hashes the attributes of ended spans and their
+// events by key before passing them to the next processor
+type RedactProcessor struct {
+	next    sdktrace.SpanProcessor
+	rules   []RedactRule
+	hashKey []byte
+}
+
+var _ sdktrace.SpanProcessor = (*RedactProcessor)(nil)
+
+// NewRedactProcessor creates a new RedactProcessor
+func NewRedactProcessor(config *RedactConfig) *RedactProcessor {
+	return &RedactProcessor{
+		next:    config.Next,
+		rules:   config.Rules,
+		hashKey: config.HashKey,
+	}
+}
+
+// OnStart passes the span to the next processor
+func (p *RedactProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, s)
+}
+
+// OnEnd redacts the attributes of s and passes it to the next processor
+func (p *RedactProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	attrs, changed := p.redact(s.Attributes())
+
+	events := s.Events()
+	eventsCopied := false
+	for i, event := range events {
+		eventAttrs, ok := p.redact(event.Attributes)
+		if !ok {
+			continue
+		}
+		if !eventsCopied {
+			events = append([]sdktrace.Event(nil), events...)
+			eventsCopied = true
+		}
+		events[i].Attributes = eventAttrs
+		changed = true
+	}
+
+	if !changed {
+		p.next.OnEnd(s)
+		return
+	}
+	p.next.OnEnd(rewrittenSpan{ReadOnlySpan: s, attrs: attrs, events: events})
+}
+
+// Shutdown shuts down the next processor
+func (p *RedactProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+// ForceFlush flushes the next processor
+func (p *RedactProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// redact returns attrs with the rules applied and whether any matched.
+// attrs itself is not modified.
+func (p *RedactProcessor) redact(attrs []attribute.KeyValue) ([]attribute.KeyValue, bool) {
+	var redacted []attribute.KeyValue
+	for i, attr := range attrs {
+		rule, ok := p.rule(attr.Key)
+		if !ok {
+			if redacted != nil {
+				redacted = append(redacted, attr)
+			}
+			continue
+		}
+		if redacted == nil {
+			redacted = append(make([]attribute.KeyValue, 0, len(attrs)), attrs[:i]...)
+		}
+		if rule.Action == RedactHash {
+			redacted = append(redacted, attr.Key.String(p.hash(attr.Value.Emit())))
+		}
+	}
+	if redacted == nil {
+		return attrs, false
+	}
+	return redacted, true
+}
+
+// rule returns the first rule matching key
+func (p *RedactProcessor) rule(key attribute.Key) (RedactRule, bool) {
+	for _, r := range p.rules {
+		if r.matches(key) {
+			return r, true
+		}
+	}
+	return RedactRule{}, false
+}
+
+// hash returns the hex encoded SHA-256 or HMAC-SHA256 of value
+func (p *RedactProcessor) hash(value string) string {
+	if len(p.hashKey) == 0 {
+		sum := sha256.Sum256([]byte(value))
+		return hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, p.hashKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,143 @@
+package spanproc
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// Attributes set by SlowSpanProcessor
+const (
+	KeySlow          = attribute.Key("slow")
+	KeyLatencyBucket = attribute.Key("latency.bucket")
+)
+
+// Span categories of SlowSpanConfig.Thresholds. CategoryDB covers every
+// span with db.system; the others are span kinds.
+const (
+	CategoryDB       = "db"
+	CategoryServer   = "server"
+	CategoryClient   = "client"
+	CategoryProducer = "producer"
+	CategoryConsumer = "consumer"
+	CategoryInternal = "internal"
+)
+
+// DefaultLatencyBuckets are the upper bounds of the latency.bucket values
+var DefaultLatencyBuckets = []time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// SlowSpanConfig holds configuration for SlowSpanProcessor
+type SlowSpanConfig struct {
+	// Next receives the marked spans, typically the batch span processor of
+	// the exporter. Required.
+	Next sdktrace.SpanProcessor
+
+	// Thresholds maps a span category (CategoryDB, CategoryServer, ...) to
+	// the duration from which its spans are slow. Spans of categories
+	// without a threshold are passed on unchanged.
+	Thresholds map[string]time.Duration
+
+	// Buckets are the ascending upper bounds of latency.bucket. Defaults to
+	// DefaultLatencyBuckets.
+	Buckets []time.Duration
+}
+
+// SlowSpanProcessor marks ended spans at or above the threshold of their
+// category with slow=true, and sets latency.bucket (e.g. "100ms-250ms") on
+// every span of a category with a threshold, so monitors on slow calls can
+// be built from span attributes without tail sampling
+type SlowSpanProcessor struct {
+	next       sdktrace.SpanProcessor
+	thresholds map[string]time.Duration
+	buckets    []string
+	bounds     []time.Duration
+}
+
+var _ sdktrace.SpanProcessor = (*SlowSpanProcessor)(nil)
+
+// NewSlowSpanProcessor creates a new SlowSpanProcessor
+func NewSlowSpanProcessor(config *SlowSpanConfig) *SlowSpanProcessor {
+	bounds := config.Buckets
+	if len(bounds) == 0 {
+		bounds = DefaultLatencyBuckets
+	}
+
+	// One label below each bound and one from the last bound up
+	buckets := make([]string, len(bounds)+1)
+	buckets[0] = "<" + bounds[0].String()
+	for i := 1; i < len(bounds); i++ {
+		buckets[i] = bounds[i-1].String() + "-" + bounds[i].String()
+	}
+	buckets[len(bounds)] = ">=" + bounds[len(bounds)-1].String()
+
+	return &SlowSpanProcessor{
+		next:       config.Next,
+		thresholds: config.Thresholds,
+		buckets:    buckets,
+		bounds:     bounds,
+	}
+}
+
+// OnStart passes the span to the next processor
+func (p *SlowSpanProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, s)
+}
+
+// OnEnd marks s when it is slow and passes it to the next processor
+func (p *SlowSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	threshold, ok := p.thresholds[spanCategory(s)]
+	if !ok {
+		p.next.OnEnd(s)
+		return
+	}
+
+	elapsed := s.EndTime().Sub(s.StartTime())
+	attrs := append([]attribute.KeyValue(nil), s.Attributes()...)
+	attrs = append(attrs, KeyLatencyBucket.String(p.bucket(elapsed)))
+	if elapsed >= threshold {
+		attrs = append(attrs, KeySlow.Bool(true))
+	}
+	p.next.OnEnd(rewrittenSpan{ReadOnlySpan: s, attrs: attrs, events: s.Events()})
+}
+
+// Shutdown shuts down the next processor
+func (p *SlowSpanProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+// ForceFlush flushes the next processor
+func (p *SlowSpanProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// bucket returns the latency.bucket label of elapsed
+func (p *SlowSpanProcessor) bucket(elapsed time.Duration) string {
+	for i, bound := range p.bounds {
+		if elapsed < bound {
+			return p.buckets[i]
+		}
+	}
+	return p.buckets[len(p.bounds)]
+}
+
+// spanCategory returns CategoryDB for database spans and the span kind
+// otherwise
+func spanCategory(s sdktrace.ReadOnlySpan) string {
+	if _, ok := attributeValue(s, semconv.DBSystemKey); ok {
+		return CategoryDB
+	}
+	return s.SpanKind().String()
+}
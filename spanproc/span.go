@@ -0,0 +1,34 @@
+package spanproc
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// rewrittenSpan is an ended span with attributes rewritten by a processor
+// before it is passed on to the next one
+type rewrittenSpan struct {
+	sdktrace.ReadOnlySpan
+	attrs  []attribute.KeyValue
+	events []sdktrace.Event
+}
+
+// Attributes returns the rewritten attributes
+func (s rewrittenSpan) Attributes() []attribute.KeyValue {
+	return s.attrs
+}
+
+// Events returns the events with their rewritten attributes
+func (s rewrittenSpan) Events() []sdktrace.Event {
+	return s.events
+}
+
+// rewriteAttributes returns s with the attributes returned by fn, or s
+// itself when fn changed none of them. fn gets a copy it may modify.
+func rewriteAttributes(s sdktrace.ReadOnlySpan, fn func(attrs []attribute.KeyValue) bool) sdktrace.ReadOnlySpan {
+	attrs := append([]attribute.KeyValue(nil), s.Attributes()...)
+	if !fn(attrs) {
+		return s
+	}
+	return rewrittenSpan{ReadOnlySpan: s, attrs: attrs, events: s.Events()}
+}
@@ -0,0 +1,182 @@
+// Package spanproc holds the span processors that prepare the spans of the
+// application for Datadog before they are exported.
+package spanproc
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// KeySpanType is the Datadog span type attribute
+const KeySpanType = attribute.Key("span.type")
+
+// DefaultNamePrefixes are the span name prefixes of SQL spans (otelsql)
+var DefaultNamePrefixes = []string{"database/sql."}
+
+// DefaultScopes are the instrumentation scopes that create only SQL spans.
+// pgxdbm is not among them because its listener creates consumer spans;
+// its query spans are matched by db.system.
+var DefaultScopes = []string{"github.com/XSAM/otelsql"}
+
+// Matcher reports the span.type of a starting span, or false when it does
+// not recognize the span
+type Matcher func(s sdktrace.ReadWriteSpan) (spanType string, ok bool)
+
+// SpanTypeConfig holds configuration for SpanTypeProcessor
+type SpanTypeConfig struct {
+	// Matchers are tried first, in order
+	Matchers []Matcher
+
+	// Spans whose name starts with one of NamePrefixes or matches one of
+	// NamePatterns get span.type sql. NamePrefixes defaults to
+	// DefaultNamePrefixes when nil.
+	NamePrefixes []string
+	NamePatterns []*regexp.Regexp
+
+	// Spans of the instrumentation scopes in Scopes get span.type sql.
+	// Defaults to DefaultScopes when nil.
+	Scopes []string
+
+	// Spans matched by nothing else get their span.type from db.system
+	// unless DisableDBSystem is set: sql, db for MongoDB, cache for Redis
+	DisableDBSystem bool
+}
+
+// SpanTypeProcessor sets the Datadog span.type of database spans when they
+// start, so Datadog shows them as SQL, document store or cache calls
+type SpanTypeProcessor struct {
+	matchers []Matcher
+}
+
+var _ sdktrace.SpanProcessor = (*SpanTypeProcessor)(nil)
+
+// NewSpanTypeProcessor creates a new SpanTypeProcessor
+func NewSpanTypeProcessor(config *SpanTypeConfig) *SpanTypeProcessor {
+	var cfg SpanTypeConfig
+	if config != nil {
+		cfg = *config
+	}
+	if cfg.NamePrefixes == nil {
+		cfg.NamePrefixes = DefaultNamePrefixes
+	}
+	if cfg.Scopes == nil {
+		cfg.Scopes = DefaultScopes
+	}
+
+	matchers := append([]Matcher(nil), cfg.Matchers...)
+	if len(cfg.NamePrefixes) > 0 {
+		matchers = append(matchers, NamePrefixMatcher("sql", cfg.NamePrefixes...))
+	}
+	for _, re := range cfg.NamePatterns {
+		matchers = append(matchers, NamePatternMatcher("sql", re))
+	}
+	if len(cfg.Scopes) > 0 {
+		matchers = append(matchers, ScopeMatcher("sql", cfg.Scopes...))
+	}
+	if !cfg.DisableDBSystem {
+		matchers = append(matchers, DBSystemMatcher)
+	}
+	return &SpanTypeProcessor{matchers: matchers}
+}
+
+// NamePrefixMatcher matches spans whose name starts with one of prefixes
+func NamePrefixMatcher(spanType string, prefixes ...string) Matcher {
+	return func(s sdktrace.ReadWriteSpan) (string, bool) {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(s.Name(), prefix) {
+				return spanType, true
+			}
+		}
+		return "", false
+	}
+}
+
+// NamePatternMatcher matches spans whose name matches re
+func NamePatternMatcher(spanType string, re *regexp.Regexp) Matcher {
+	return func(s sdktrace.ReadWriteSpan) (string, bool) {
+		return spanType, re.MatchString(s.Name())
+	}
+}
+
+// ScopeMatcher matches spans created by one of the instrumentation scopes
+func ScopeMatcher(spanType string, scopes ...string) Matcher {
+	return func(s sdktrace.ReadWriteSpan) (string, bool) {
+		name := s.InstrumentationScope().Name
+		for _, scope := range scopes {
+			if name == scope {
+				return spanType, true
+			}
+		}
+		return "", false
+	}
+}
+
+// AttributeMatcher matches spans that have the attribute key at start
+// (optionally with one of values, any value when empty)
+func AttributeMatcher(spanType string, key attribute.Key, values ...string) Matcher {
+	return func(s sdktrace.ReadWriteSpan) (string, bool) {
+		v, ok := attributeValue(s, key)
+		if !ok {
+			return "", false
+		}
+		if len(values) == 0 {
+			return spanType, true
+		}
+		for _, want := range values {
+			if v.Emit() == want {
+				return spanType, true
+			}
+		}
+		return "", false
+	}
+}
+
+// DBSystemMatcher derives the span.type from db.system: db for MongoDB,
+// cache for Redis and sql for everything else
+func DBSystemMatcher(s sdktrace.ReadWriteSpan) (string, bool) {
+	v, ok := attributeValue(s, semconv.DBSystemKey)
+	if !ok {
+		return "", false
+	}
+	switch v.AsString() {
+	case semconv.DBSystemMongoDB.Value.AsString():
+		return "db", true
+	case semconv.DBSystemRedis.Value.AsString():
+		return "cache", true
+	}
+	return "sql", true
+}
+
+// OnStart sets span.type from the first matcher that recognizes the span
+func (p *SpanTypeProcessor) OnStart(_ context.Context, s sdktrace.ReadWriteSpan) {
+	for _, m := range p.matchers {
+		if spanType, ok := m(s); ok {
+			s.SetAttributes(KeySpanType.String(spanType))
+			return
+		}
+	}
+}
+
+// OnEnd does nothing
+func (p *SpanTypeProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+// Shutdown does nothing
+func (p *SpanTypeProcessor) Shutdown(context.Context) error { return nil }
+
+// ForceFlush does nothing
+func (p *SpanTypeProcessor) ForceFlush(context.Context) error { return nil }
+
+// attributeValue returns the value of key among the attributes s started with
+func attributeValue(s sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, attr := range s.Attributes() {
+		if attr.Key == key {
+			return attr.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
@@ -0,0 +1,207 @@
+package spanproc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LatencySampler wraps a head sampler so the spans it drops are still
+// recorded, though not sampled, for TailLatencyProcessor to export when
+// their trace turns out to be slow
+func LatencySampler(base sdktrace.Sampler) sdktrace.Sampler {
+	return latencySampler{base: base}
+}
+
+type latencySampler struct {
+	base sdktrace.Sampler
+}
+
+// ShouldSample records what the base sampler drops
+func (s latencySampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	result := s.base.ShouldSample(p)
+	if result.Decision == sdktrace.Drop {
+		result.Decision = sdktrace.RecordOnly
+	}
+	return result
+}
+
+// Description describes the sampler
+func (s latencySampler) Description() string {
+	return "LatencySampler{" + s.base.Description() + "}"
+}
+
+// TailLatencyConfig holds configuration for TailLatencyProcessor
+type TailLatencyConfig struct {
+	// Next receives the sampled spans and the spans of slow traces,
+	// typically the batch span processor of the exporter. Required.
+	Next sdktrace.SpanProcessor
+
+	// Threshold is the duration of the local root span from which its
+	// trace is kept. Required.
+	Threshold time.Duration
+
+	// MaxTraces is the number of unsampled traces buffered at a time.
+	// Traces starting while the buffer is full are dropped. Defaults to 1000.
+	MaxTraces int
+
+	// MaxSpansPerTrace is the number of spans buffered per trace; further
+	// spans are dropped. Defaults to 1000.
+	MaxSpansPerTrace int
+
+	// Timeout evicts traces whose local root has not ended after it, e.g.
+	// when a span was never ended. Defaults to 1m.
+	Timeout time.Duration
+}
+
+// TailLatencyProcessor exports every trace whose local root span takes at
+// least the threshold, even when the head sampler did not sample it.
+//
+// Use it with LatencySampler, so unsampled spans are recorded. Sampled spans
+// are passed to the next processor as they end. Unsampled spans are
+// buffered per trace until the local root span ends, then passed on marked
+// as sampled when the root was slow and dropped otherwise. Spans ending
+// after their local root, e.g. background work, are dropped. Downstream
+// services see the trace as unsampled, as the decision is made at the end.
+type TailLatencyProcessor struct {
+	next      sdktrace.SpanProcessor
+	threshold time.Duration
+	maxTraces int
+	maxSpans  int
+	timeout   time.Duration
+
+	mu     sync.Mutex
+	traces map[trace.TraceID]*pendingTrace
+}
+
+// pendingTrace is an unsampled trace whose local root has not ended
+type pendingTrace struct {
+	started time.Time
+	spans   []sdktrace.ReadOnlySpan
+}
+
+var _ sdktrace.SpanProcessor = (*TailLatencyProcessor)(nil)
+
+// NewTailLatencyProcessor creates a new TailLatencyProcessor
+func NewTailLatencyProcessor(config *TailLatencyConfig) *TailLatencyProcessor {
+	cfg := *config
+	if cfg.MaxTraces <= 0 {
+		cfg.MaxTraces = 1000
+	}
+	if cfg.MaxSpansPerTrace <= 0 {
+		cfg.MaxSpansPerTrace = 1000
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Minute
+	}
+	return &TailLatencyProcessor{
+		next:      cfg.Next,
+		threshold: cfg.Threshold,
+		maxTraces: cfg.MaxTraces,
+		maxSpans:  cfg.MaxSpansPerTrace,
+		timeout:   cfg.Timeout,
+		traces:    make(map[trace.TraceID]*pendingTrace),
+	}
+}
+
+// OnStart starts buffering the trace of an unsampled local root span
+func (p *TailLatencyProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, s)
+	if s.SpanContext().IsSampled() || !isLocalRoot(s) {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	traceID := s.SpanContext().TraceID()
+	if _, ok := p.traces[traceID]; ok {
+		return
+	}
+	if len(p.traces) >= p.maxTraces {
+		p.evictExpired()
+		if len(p.traces) >= p.maxTraces {
+			return
+		}
+	}
+	p.traces[traceID] = &pendingTrace{started: time.Now()}
+}
+
+// OnEnd passes sampled spans on, buffers unsampled ones and decides the
+// trace when its local root ends
+func (p *TailLatencyProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		p.next.OnEnd(s)
+		return
+	}
+
+	p.mu.Lock()
+	traceID := s.SpanContext().TraceID()
+	t, ok := p.traces[traceID]
+	if !ok {
+		p.mu.Unlock()
+		return
+	}
+	if !isLocalRoot(s) {
+		if len(t.spans) < p.maxSpans {
+			t.spans = append(t.spans, s)
+		}
+		p.mu.Unlock()
+		return
+	}
+	delete(p.traces, traceID)
+	p.mu.Unlock()
+
+	if s.EndTime().Sub(s.StartTime()) < p.threshold {
+		return
+	}
+	for _, span := range t.spans {
+		p.next.OnEnd(sampledSpan{span})
+	}
+	p.next.OnEnd(sampledSpan{s})
+}
+
+// Shutdown drops the buffered traces and shuts down the next processor
+func (p *TailLatencyProcessor) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	p.traces = make(map[trace.TraceID]*pendingTrace)
+	p.mu.Unlock()
+	return p.next.Shutdown(ctx)
+}
+
+// ForceFlush flushes the next processor. Undecided traces stay buffered.
+func (p *TailLatencyProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// evictExpired drops the traces buffered for longer than the timeout.
+// p.mu must be held.
+func (p *TailLatencyProcessor) evictExpired() {
+	for id, t := range p.traces {
+		if time.Since(t.started) > p.timeout {
+			delete(p.traces, id)
+		}
+	}
+}
+
+// isLocalRoot reports whether s is the first span of its trace in this
+// process
+func isLocalRoot(s sdktrace.ReadOnlySpan) bool {
+	return !s.Parent().IsValid() || s.Parent().IsRemote()
+}
+
+// sampledSpan is an unsampled span kept by TailLatencyProcessor, marked as
+// sampled so the batch span processor exports it
+type sampledSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+// SpanContext returns the span context with the sampled flag set and the
+// Datadog sampling priority of the tracestate raised to auto keep
+func (s sampledSpan) SpanContext() trace.SpanContext {
+	sc := s.ReadOnlySpan.SpanContext()
+	return sc.WithTraceFlags(sc.TraceFlags().WithSampled(true)).
+		WithTraceState(keepTraceState(sc.TraceState()))
+}
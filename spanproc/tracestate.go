@@ -0,0 +1,153 @@
+package spanproc
+
+import (
+	"strconv"
+	"strings"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DatadogTraceStateKey is the tracestate member of Datadog tracers
+const DatadogTraceStateKey = "dd"
+
+// Datadog sampling priorities
+const (
+	PriorityAutoReject = 0
+	PriorityAutoKeep   = 1
+)
+
+// DatadogTraceStateConfig holds configuration for DatadogTraceStateSampler
+type DatadogTraceStateConfig struct {
+	// Origin is written as o:<origin> when the parent carries none, e.g.
+	// synthetics. Optional.
+	Origin string
+}
+
+// DatadogTraceStateSampler wraps a sampler so every span carries its
+// sampling decision in the dd tracestate member (dd=s:<priority>;o:<origin>),
+// which TraceContext propagation sends with outgoing requests and the
+// Commenter writes to SQL comments. The Datadog Agent and downstream
+// Datadog tracers then honor the decision instead of sampling again.
+//
+// The priority of the parent is kept when it agrees with the decision, so
+// a user keep (2) or user reject (-1) upstream is passed on; otherwise it is
+// auto keep (1) for sampled spans and auto reject (0) for the others. The
+// origin and the t.* propagation tags of the parent are kept.
+func DatadogTraceStateSampler(base sdktrace.Sampler, config *DatadogTraceStateConfig) sdktrace.Sampler {
+	var cfg DatadogTraceStateConfig
+	if config != nil {
+		cfg = *config
+	}
+	return traceStateSampler{base: base, origin: sanitizeTraceStateValue(cfg.Origin)}
+}
+
+type traceStateSampler struct {
+	base   sdktrace.Sampler
+	origin string
+}
+
+// ShouldSample sets the dd member of the tracestate from the decision
+func (s traceStateSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	result := s.base.ShouldSample(p)
+	sampled := result.Decision == sdktrace.RecordAndSample
+
+	var (
+		priority = PriorityAutoReject
+		origin   = s.origin
+		tags     []string
+	)
+	if sampled {
+		priority = PriorityAutoKeep
+	}
+	for _, field := range strings.Split(result.Tracestate.Get(DatadogTraceStateKey), ";") {
+		key, value, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		switch {
+		case key == "s":
+			// Keep the parent's priority when it agrees with the decision
+			if parent, err := strconv.Atoi(value); err == nil && (parent > 0) == sampled {
+				priority = parent
+			}
+		case key == "o":
+			origin = value
+		case strings.HasPrefix(key, "t."):
+			tags = append(tags, field)
+		}
+	}
+
+	value := "s:" + strconv.Itoa(priority)
+	if origin != "" {
+		value += ";o:" + origin
+	}
+	for _, tag := range tags {
+		value += ";" + tag
+	}
+	if ts, err := result.Tracestate.Insert(DatadogTraceStateKey, value); err == nil {
+		result.Tracestate = ts
+	}
+	return result
+}
+
+// Description describes the sampler
+func (s traceStateSampler) Description() string {
+	return "DatadogTraceStateSampler{" + s.base.Description() + "}"
+}
+
+// sanitizeTraceStateValue replaces the characters Datadog tracers replace
+// in dd member values: ',', ';' and '=' would break the tracestate and
+// '~' stands for '=' on the way back
+func sanitizeTraceStateValue(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '=':
+			return '~'
+		case r == ',' || r == ';' || r == '~' || r < 0x20 || r > 0x7e:
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// keepTraceState returns ts with the priority of its dd member raised to
+// auto keep, for spans kept after the head sampler dropped them. The Agent
+// would otherwise honor the s:0 of the head decision and drop them.
+func keepTraceState(ts trace.TraceState) trace.TraceState {
+	dd := ts.Get(DatadogTraceStateKey)
+	if dd == "" {
+		return ts
+	}
+	fields := strings.Split(dd, ";")
+	changed := false
+	for i, field := range fields {
+		value, ok := strings.CutPrefix(field, "s:")
+		if !ok {
+			continue
+		}
+		if priority, err := strconv.Atoi(value); err != nil || priority < PriorityAutoKeep {
+			fields[i] = "s:" + strconv.Itoa(PriorityAutoKeep)
+			changed = true
+		}
+	}
+	if !changed {
+		return ts
+	}
+	if updated, err := ts.Insert(DatadogTraceStateKey, strings.Join(fields, ";")); err == nil {
+		return updated
+	}
+	return ts
+}
+
+// DatadogSamplingPriority returns the sampling priority of the dd member of
+// sc's tracestate
+func DatadogSamplingPriority(sc trace.SpanContext) (int, bool) {
+	for _, field := range strings.Split(sc.TraceState().Get(DatadogTraceStateKey), ";") {
+		if value, ok := strings.CutPrefix(field, "s:"); ok {
+			priority, err := strconv.Atoi(value)
+			return priority, err == nil
+		}
+	}
+	return 0, false
+}